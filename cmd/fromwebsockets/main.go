@@ -1,79 +1,154 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net/url"
+	"os"
+	"os/signal"
 	"time"
 
 	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/config"
 	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/history"
 )
 
 func main() {
-	var addr = flag.String("addr", "129.12.44.246:1234", "http service address") //Submission
-	// var addr = flag.String("addr", "129.12.44.229:1234", "http service address") //Test
-	u := url.URL{Scheme: "ws", Host: *addr, Path: "/"}
-	timeout := time.Minute * 30
-
-	log.Printf("Connecting to problem server (%v) 🤖\n", u.String())
+	// The test server lives at 129.12.44.229:1234
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	config := dag.ParamConfig{
-		Limit:     5000,
-		Divisions: 50,
-		Merges:    100,
+	if cfg.PrintConfig {
+		fmt.Println(cfg)
+		return
 	}
 
-	conn, err := bisect.ConnectWebsocket(u, timeout)
+	err = cfg.Validate()
 	if err != nil {
-		log.Print("Could not connect to websocket 🤖😢")
-		log.Fatal(err)
+		log.Fatalf("Invalid config: %v", err)
 	}
-	defer conn.WS.Close()
 
-	log.Println("Connected to websocket 🤖✅")
+	log.Printf("Using parameters %+v\n", cfg.Params)
 
-	auth := bisect.Authentication{
-		User: []string{"jj333", "30e8e949"},
+	opts := bisect.Options{Logs: cfg.BisectLogs, Batch: cfg.Batch}
+	if cfg.BisectLogs != "" {
+		err = os.MkdirAll(cfg.BisectLogs, 0755)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.Weights != "" {
+		opts.Weights, err = dag.LoadWeights(cfg.Weights)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Weighting %v commits from %v ⚖️", len(opts.Weights), cfg.Weights)
+	}
+	if cfg.Costs != "" {
+		opts.Costs, err = dag.LoadCosts(cfg.Costs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Costing %v commits from %v 💸", len(opts.Costs), cfg.Costs)
 	}
 
+	// The first Ctrl-C stops after the current question and saves what we have, the second one kills us
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		log.Print("Interrupted, stopping after the current question 🛑 (interrupt again to quit now)")
+		signal.Stop(interrupt)
+		cancel()
+	}()
+
+	// Before anything is solved, as the results are written into the checkout
+	revision := history.BuildRevision(".")
 	STARTTIME := time.Now()
 
-	problem, err := conn.GetProblemWebsocket(auth)
-	if err != nil {
-		log.Print("You... Shall... not.... be authorised to connect to this server 😢")
+	var score bisect.Score
+	if cfg.Local != "" {
+		score, err = solveLocal(ctx, cfg, opts)
+	} else {
+		score, err = solveRemote(ctx, cfg, opts)
+	}
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Stopped early, saving partial results for %v problems", len(score.Score))
+	} else if err != nil {
 		log.Fatal(err)
 	}
 
-	log.Printf("Retrieved problem %v, parsing...", problem.Repo.Name)
-
-	newDag := bisect.DAGMaker(&problem.Repo)
-
-	log.Printf("Problem: %v has %v vertexes (commits) and %v edges with new dag map\n", problem.Repo.Name, newDag.GetOrder(), newDag.GetSize())
+	log.Printf("%v", score)
 
-	err = newDag.GoodCommit(problem.Instance.Good)
+	err = bisect.SaveReport(&score, STARTTIME, cfg.Results, cfg.ResultsFormat)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// log.Printf("Problem: %v now has %v commits after GOOD (%v)\n", problem.Name, newDag.GetOrder(), problem.Good)
+	if cfg.History != "" {
+		run := history.NewRun(revision, cfg.Params, score.Problems(), STARTTIME, time.Now())
+		err = history.Append(cfg.History, run)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Saved run %v (%v) to %v", run.ID, run.Revision, cfg.History)
+	}
+}
 
-	err = newDag.BadCommit(problem.Instance.Bad)
+// solveLocal solves the test problems in process, with a worker for each connection
+func solveLocal(ctx context.Context, cfg *config.Config, opts bisect.Options) (bisect.Score, error) {
+	cases, err := bisect.LoadTestCases(cfg.Local)
 	if err != nil {
-		log.Fatal(err)
+		return bisect.Score{}, err
 	}
 
-	// log.Printf("Problem: %v now has %v commits after BAD (%v)\n", problem.Name, newDag.GetOrder(), problem.Bad)
+	log.Printf("Solving %v local problems with %v workers 🤖\n", len(cases), cfg.Connections)
 
-	score, err := conn.NextMoveWebsocket(newDag, config, problem)
-	if err != nil {
-		log.Fatal(err)
+	return bisect.SolveLocalPool(ctx, cfg.Connections, cases, cfg.Params, opts)
+}
+
+// solveRemote solves the server's problems, over several connections if asked to
+func solveRemote(ctx context.Context, cfg *config.Config, opts bisect.Options) (bisect.Score, error) {
+	u := url.URL{Scheme: "ws", Host: cfg.Addr, Path: "/"}
+	auth := bisect.Authentication{
+		User: []string{cfg.User, cfg.Token},
 	}
 
-	log.Printf("%v", score)
+	if cfg.DAGCache != "" {
+		var err error
+		opts.Cache, err = bisect.NewRepoCache(cfg.DAGCache, cfg.DAGCacheByName)
+		if err != nil {
+			return bisect.Score{}, err
+		}
+	}
 
-	err = bisect.SaveResults(&score, STARTTIME)
+	log.Printf("Connecting to problem server (%v) 🤖\n", u.String())
+
+	if cfg.Connections > 1 {
+		return bisect.SolvePool(ctx, cfg.Connections, u, cfg.Timeout, auth, cfg.Params, opts)
+	}
+
+	conn, err := bisect.ConnectWebsocket(ctx, u, cfg.Timeout)
 	if err != nil {
-		log.Fatal(err)
+		log.Print("Could not connect to websocket 🤖😢")
+		return bisect.Score{}, err
 	}
+	defer conn.Close()
+	conn.Options = opts
+
+	log.Println("Connected to websocket 🤖✅")
+
+	return conn.Solve(ctx, auth, cfg.Params)
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/gen"
+)
+
+func main() {
+	var families = flag.String("family", "all", "comma separated families to generate ("+strings.Join(gen.Names(), ", ")+"), or all")
+	var size = flag.String("size", "tiny", "commits per repo, as tiny, small, medium, large or a number")
+	var n = flag.Int("n", 10, "problems to generate per family")
+	var seed = flag.Int64("seed", 1, "random seed, the same seed always gives the same problems")
+	var out = flag.String("out", "tests/generated", "directory to write the test problems to")
+	flag.Parse()
+
+	commits, err := gen.ParseSize(*size)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names := gen.Names()
+	if *families != "all" {
+		names = strings.Split(*families, ",")
+	}
+
+	err = os.MkdirAll(*out, 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := rand.New(rand.NewSource(*seed))
+	for _, family := range names {
+		f, ok := gen.Families[family]
+		if !ok {
+			log.Fatalf("Unknown family '%v', expected one of %v", family, strings.Join(gen.Names(), ", "))
+		}
+
+		for i := 0; i < *n; i++ {
+			name := fmt.Sprintf("%v-%v-%v", *size, family, i)
+			t := gen.TestCase(f, name, commits, r.Int63())
+
+			err = bisect.SaveTestCase(filepath.Join(*out, "test_"+name+".json"), t)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		log.Printf("Generated %v %v problems of %v commits 🌱\n", *n, family, commits)
+	}
+}
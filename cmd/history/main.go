@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jamesjarvis/git-bisect/pkg/history"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [-file history.jsonl] <command>
+
+Commands:
+  list                 list every run
+  compare <old> <new>  compare two runs problem by problem (default: latest~1 latest)
+
+Runs are given by ID (or any start of it only one run has), or as latest, latest~1, latest~2 and so on.
+compare exits with status 1 if any problem got worse.
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var file = flag.String("file", "history.jsonl", "results history file, written by fromwebsockets -history")
+	var all = flag.Bool("all", false, "show the problems that got better as well as the ones that got worse")
+	flag.Usage = usage
+	flag.Parse()
+
+	runs, err := history.Load(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "list", "":
+		list(runs)
+	case "compare":
+		older, newer := flag.Arg(1), flag.Arg(2)
+		if older == "" {
+			older = "latest~1"
+		}
+		if newer == "" {
+			newer = "latest"
+		}
+
+		before, err := history.Find(runs, older)
+		if err != nil {
+			log.Fatal(err)
+		}
+		after, err := history.Find(runs, newer)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if compare(before, after, *all) {
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func list(runs []history.Run) {
+	for _, run := range runs {
+		s := report.New(run.Problems, run.Start, run.End).Summary
+		fmt.Printf("%v  %-12v %v/%v correct, %v questions (%.2f avg), took %v, %+v\n",
+			run.ID, run.Revision, s.Correct, s.Problems, s.Questions, s.Mean, run.End.Sub(run.Start), run.Params)
+	}
+}
+
+// compare prints how every problem changed, and returns true if any got worse
+func compare(before, after history.Run, all bool) bool {
+	fmt.Printf("Comparing %v (%v) -> %v (%v)\n", before.ID, before.Revision, after.ID, after.Revision)
+	if before.Params != after.Params {
+		fmt.Printf("Params changed: %+v -> %+v\n", before.Params, after.Params)
+	}
+
+	changes := history.Compare(before, after)
+
+	worse, better := 0, 0
+	for _, c := range changes {
+		if c.Worse {
+			worse++
+		} else {
+			better++
+		}
+		if !c.Worse && !all {
+			continue
+		}
+
+		switch {
+		case c.Before == nil:
+			fmt.Printf("➕ %v: only in the new run (%v)\n", c.Name, describe(c.After))
+		case c.After == nil:
+			fmt.Printf("➖ %v: only in the old run (%v)\n", c.Name, describe(c.Before))
+		case c.Delta != 0:
+			fmt.Printf("%v %v: %v -> %v questions (%+d)\n", marker(c.Worse), c.Name, c.Before.Questions, c.After.Questions, c.Delta)
+		default:
+			fmt.Printf("%v %v: %v -> %v\n", marker(c.Worse), c.Name, describe(c.Before), describe(c.After))
+		}
+	}
+
+	b := report.New(before.Problems, before.Start, before.End).Summary
+	a := report.New(after.Problems, after.Start, after.End).Summary
+	fmt.Printf("Total: %v -> %v questions (%+d), %v -> %v correct, %v worse, %v other changes\n",
+		b.Questions, a.Questions, a.Questions-b.Questions, b.Correct, a.Correct, worse, better)
+
+	return worse > 0
+}
+
+func marker(worse bool) string {
+	if worse {
+		return "⚠️ "
+	}
+	return "✅"
+}
+
+func describe(p *report.Problem) string {
+	if p.Outcome == report.Correct || p.Outcome == report.Submitted {
+		return fmt.Sprintf("%v with %v questions", p.Outcome, p.Questions)
+	}
+	return p.Outcome
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/config"
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s -repo <problem or git repository> [-good <commit>] [-bad <commit>] [flags]
+
+Bisects with you answering the questions, instead of the server.
+The repo is a local git repository, a problem from the tests directory, a Repo message saved from the server
+or a DAG saved with dag.Save. A problem has its own good and bad commits, everything else needs -good
+(and -bad, which for a git repository defaults to HEAD).
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+const help = `Commands:
+  good [commit]    the question (or the commit) is good
+  bad [commit]     the question (or the commit) is bad
+  skip [commit]    the question (or the commit) can't be tested, ask something else
+  undo [n]         take back the last answer (or the last n)
+  visualize        draw the commits that are still candidates
+  why <commit>     say why a commit is or isn't still a candidate
+  log              print the bisect log so far
+  help             print this
+  quit             stop
+Commands can be shortened to their first letter, and commits to the start of their sha.`
+
+// maxDrawn is the most candidates visualize will draw
+const maxDrawn = 50
+
+func main() {
+	var repo = flag.String("repo", ".", "git repository, test problem, Repo message or saved DAG to bisect")
+	var good = flag.String("good", "", "a commit without the bug")
+	var bad = flag.String("bad", "", "a commit with the bug (default for a git repository: HEAD)")
+	var logFile = flag.String("log", "", "file to save the bisect log to after every answer, for cmd/replay")
+	var weights = flag.String("weights", "", "json file of prior weights for the commits, {\"<sha>\": 2.5, ...}")
+	var costs = flag.String("costs", "", "json file of how expensive the commits are to test, {\"<sha>\": 10, \"<sha>\": \"untestable\", ...}")
+	var strategy = flag.String("strategy", dag.StrategyAuto, "midpoint strategy, one of "+strings.Join(dag.Strategies, ", "))
+	var firstParent = flag.Bool("first-parent", false, "only bisect the first-parent chain of the bad commit, like git bisect --first-parent")
+	var paths = flag.String("paths", "", "comma separated paths, to only bisect the commits that change them, like git bisect -- <paths>")
+	var tags = flag.String("tags", "", "json file of the paths each commit changes for -paths, {\"<sha>\": [\"src/parser.go\"], ...} (default for a git repository: ask git)")
+	flag.Usage = usage
+	flag.Parse()
+
+	// The solver's own logging would get in the way of the questions
+	log.SetFlags(0)
+	logger := log.New(os.Stderr, "", 0)
+	log.SetOutput(ioutil.Discard)
+
+	s, err := load(*repo, *good, *bad)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if *weights != "" {
+		w, err := dag.LoadWeights(*weights)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		s.c.SetWeights(w)
+	}
+	if *costs != "" {
+		c, err := dag.LoadCosts(*costs)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		s.c.SetCosts(c)
+	}
+	if *firstParent || *paths != "" || *tags != "" {
+		var limitTo []string
+		if *paths != "" {
+			limitTo = strings.Split(*paths, ",")
+		}
+		err := s.limit(*firstParent, limitTo, *tags)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	s.pc = config.Default().Params
+	s.pc.Strategy = *strategy
+	s.logFile = *logFile
+	if err := config.ValidateParams(s.pc); err != nil {
+		logger.Fatal(err)
+	}
+
+	err = s.run(os.Stdin, os.Stdout)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// session is a bisection with a human answering the questions
+type session struct {
+	problem bisect.ProblemInstance
+	c       *dag.Candidates
+	pc      dag.ParamConfig
+	skip    map[string]bool
+	// commits is every commit in the repo in order, to find the one a shortened sha means
+	commits []string
+	// gitDir is the git repository, if it came from one, for describing commits and resolving revisions
+	gitDir  string
+	logFile string
+	out     io.Writer
+
+	// question is what we are asking about, which is only worked out again when the candidates change,
+	// as the estimated midpoint of a big DAG can be different every time
+	question string
+	stale    bool
+}
+
+// load works out what the repo is and gets it ready to bisect
+func load(path, good, bad string) (*session, error) {
+	s := &session{skip: make(map[string]bool)}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case info.IsDir():
+		s.gitDir = path
+		if bad == "" {
+			bad = "HEAD"
+		}
+		bad, err = bisect.ResolveGit(path, bad)
+		if err != nil {
+			return nil, err
+		}
+		if good == "" {
+			return nil, fmt.Errorf("-good is needed for a git repository")
+		}
+		good, err = bisect.ResolveGit(path, good)
+		if err != nil {
+			return nil, err
+		}
+		s.problem.Repo, err = bisect.RepoFromGit(path, bad)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		s.problem, err = bisect.LoadProblem(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if good != "" {
+		s.problem.Instance.Good = good
+	}
+	if bad != "" {
+		s.problem.Instance.Bad = bad
+	}
+	if s.problem.Instance.Good == "" || s.problem.Instance.Bad == "" {
+		return nil, fmt.Errorf("%s has no good and bad commits of its own, so -good and -bad are needed", path)
+	}
+
+	s.c, err = bisect.PrepareCandidates(s.problem)
+	if err != nil {
+		return nil, err
+	}
+
+	d := s.problem.DAG
+	if d == nil {
+		d = bisect.DAGMaker(&s.problem.Repo)
+	}
+	for v := range d.GetVertices() {
+		s.commits = append(s.commits, v)
+	}
+	sort.Strings(s.commits)
+
+	return s, nil
+}
+
+// limit only bisects the first-parent chain of the bad commit, and/or the commits that change the paths
+// The paths each commit changes come from the tags file if there is one, or else git.
+func (s *session) limit(firstParent bool, paths []string, tagsFile string) error {
+	var only map[string]bool
+	bad := s.problem.Instance.Bad
+
+	if firstParent {
+		if len(s.problem.Repo.Dag) == 0 {
+			return fmt.Errorf("-first-parent needs the parents in order, which a saved DAG doesn't have")
+		}
+		chain, err := bisect.FirstParentChain(&s.problem.Repo, bad)
+		if err != nil {
+			return err
+		}
+		only = chain
+	}
+
+	if len(paths) > 0 || tagsFile != "" {
+		if len(paths) == 0 {
+			return fmt.Errorf("-tags needs -paths to say which paths to bisect")
+		}
+
+		var touching map[string]bool
+		switch {
+		case tagsFile != "":
+			tags, err := bisect.LoadTags(tagsFile)
+			if err != nil {
+				return err
+			}
+			touching = tags.Touching(paths)
+		case s.gitDir != "":
+			var err error
+			touching, err = bisect.TouchingFromGit(s.gitDir, bad, paths)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("-paths needs a git repository, or -tags to say which paths each commit changes")
+		}
+
+		if only == nil {
+			only = touching
+		} else {
+			for v := range only {
+				if !touching[v] {
+					delete(only, v)
+				}
+			}
+		}
+	}
+
+	s.c.SetOnly(only)
+	return nil
+}
+
+// run asks questions until the culprit is found, and then waits in case an answer was wrong
+func (s *session) run(in io.Reader, out io.Writer) error {
+	s.out = out
+	scanner := bufio.NewScanner(in)
+
+	fmt.Fprintf(out, "Bisecting %v between good %v and bad %v (type help for the commands)\n",
+		s.problem.Repo.Name, s.describe(s.problem.Instance.Good), s.describe(s.problem.Instance.Bad))
+
+	s.stale = true
+	for {
+		if s.stale {
+			var err error
+			s.question, err = s.next()
+			if err != nil {
+				return err
+			}
+			s.stale = false
+		}
+
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		done, err := s.command(fields[0], fields[1:])
+		if err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// next says where we are, and returns the question to ask ("" if there is nothing to ask)
+func (s *session) next() (string, error) {
+	if s.c.View().GetEligibleOrder() == 0 {
+		fmt.Fprintf(s.out, "🎯 %v is the first bad commit\n", s.describe(s.c.MostRecentBad()))
+		return "", nil
+	}
+
+	question, err := s.c.View().GetMidPointSkipping(context.Background(), s.pc, s.skip)
+	if err != nil {
+		return "", err
+	}
+	if question == "" {
+		fmt.Fprintf(s.out, "There are only skipped or untestable commits left, so the first bad commit could be any of:\n")
+		for _, v := range s.candidates() {
+			fmt.Fprintf(s.out, "  %v\n", s.describe(v))
+		}
+		return "", nil
+	}
+
+	estimate, err := s.c.View().GetEstimate(question)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(s.out, "Bisecting: %v candidates left, %v\n", s.c.Len(), estimate)
+	if s.c.View().GetCosts() != nil {
+		fmt.Fprintf(s.out, "❓ Is %v good or bad? (it costs %v to test)\n", s.describe(question), s.c.View().GetCost(question))
+	} else {
+		fmt.Fprintf(s.out, "❓ Is %v good or bad?\n", s.describe(question))
+	}
+	return question, nil
+}
+
+// command does what the human typed, returning true if they want to stop
+func (s *session) command(name string, args []string) (bool, error) {
+	switch name {
+	case "good", "g", "bad", "b", "skip", "s":
+		commit := s.question
+		if len(args) > 0 {
+			var err error
+			commit, err = s.resolve(args[0])
+			if err != nil {
+				return false, err
+			}
+		}
+		if commit == "" {
+			return false, fmt.Errorf("there is nothing left to ask about, undo or give a commit")
+		}
+
+		if name[0] == 's' {
+			s.skip[commit] = true
+			s.stale = true
+			return false, s.save()
+		}
+		err := s.c.Answer(dag.Answer{Commit: commit, Good: name[0] == 'g'})
+		if err != nil {
+			return false, fmt.Errorf("%v isn't a candidate any more: %v", commit, err)
+		}
+		s.stale = true
+		return false, s.save()
+	case "undo", "u":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil {
+				return false, err
+			}
+		}
+		// The first two answers are the instance's
+		if n > len(s.c.Answers())-2 {
+			return false, fmt.Errorf("there are only %v answers to undo", len(s.c.Answers())-2)
+		}
+		for i := 0; i < n; i++ {
+			undone, err := s.c.Undo()
+			if err != nil {
+				return false, err
+			}
+			fmt.Fprintf(s.out, "Undid %v\n", undone)
+		}
+		s.stale = true
+		return false, s.save()
+	case "visualize", "v":
+		s.visualize()
+	case "why", "w":
+		if len(args) == 0 {
+			return false, fmt.Errorf("why what?")
+		}
+		commit, err := s.resolve(args[0])
+		if err != nil {
+			return false, err
+		}
+		explanation, err := s.c.Explain(commit)
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(s.out, explanation)
+	case "log", "l":
+		fmt.Fprint(s.out, s.log())
+	case "help", "h", "?":
+		fmt.Fprintln(s.out, help)
+	case "quit", "q", "exit":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command '%s', type help for the commands", name)
+	}
+	return false, nil
+}
+
+// resolve finds the commit meant, by the start of its sha or as a git revision
+func (s *session) resolve(commit string) (string, error) {
+	i := sort.SearchStrings(s.commits, commit)
+	if i < len(s.commits) && strings.HasPrefix(s.commits[i], commit) {
+		if i+1 < len(s.commits) && strings.HasPrefix(s.commits[i+1], commit) {
+			return "", fmt.Errorf("%v could be more than one commit", commit)
+		}
+		return s.commits[i], nil
+	}
+	if s.gitDir != "" {
+		return bisect.ResolveGit(s.gitDir, commit)
+	}
+	return "", fmt.Errorf("there is no commit %v", commit)
+}
+
+// describe is the commit's short sha, and its subject if it came from git
+func (s *session) describe(commit string) string {
+	if s.gitDir != "" {
+		if description, err := bisect.DescribeGit(s.gitDir, commit); err == nil {
+			return description
+		}
+	}
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
+
+// candidates are the commits that could be the culprit, the newest first
+// A commit has more ancestors than any of its own ancestors, so sorting by that is a topological order.
+func (s *session) candidates() []string {
+	d := s.c.View()
+	ancestors := make(map[string]int)
+	var commits []string
+	for v := range d.GetVertices() {
+		if !d.IsEligible(v) {
+			continue
+		}
+		ancestors[v], _ = d.GetAncestorsLength(v)
+		commits = append(commits, v)
+	}
+	sort.Slice(commits, func(i, j int) bool {
+		if ancestors[commits[i]] != ancestors[commits[j]] {
+			return ancestors[commits[i]] > ancestors[commits[j]]
+		}
+		return commits[i] < commits[j]
+	})
+	if bad := s.c.MostRecentBad(); bad != "" {
+		commits = append([]string{bad}, commits...)
+	}
+	return commits
+}
+
+// visualize draws the candidates newest first, like git bisect visualize does with git log
+func (s *session) visualize() {
+	if s.c.Len() > s.pc.Limit {
+		fmt.Fprintf(s.out, "There are %v candidates, which is too many to draw\n", s.c.Len())
+		return
+	}
+
+	parents := make(map[string]map[string]bool)
+	commits := s.candidates()
+	for _, v := range commits {
+		parents[v], _ = s.c.View().GetParents(v)
+	}
+
+	for i, v := range commits {
+		if i == maxDrawn {
+			fmt.Fprintf(s.out, "  ... and %v more\n", len(commits)-maxDrawn)
+			break
+		}
+
+		mark := "*"
+		switch {
+		case v == s.c.MostRecentBad():
+			mark = "B"
+		case v == s.question:
+			mark = "?"
+		case s.skip[v]:
+			mark = "s"
+		}
+		merge := ""
+		if len(parents[v]) > 1 {
+			merge = fmt.Sprintf(" (merge of %v)", len(parents[v]))
+		}
+		fmt.Fprintf(s.out, "  %v %v%v\n", mark, s.describe(v), merge)
+	}
+	fmt.Fprintf(s.out, "B is the most recent bad commit, ? the question and s a skipped commit\n")
+}
+
+// log is the bisect log of the answers and skips so far
+func (s *session) log() *bisect.BisectLog {
+	l := bisect.NewBisectLog(s.problem, s.c)
+	l.Skip(s.skip)
+	return l
+}
+
+// save writes the bisect log, if we were asked to
+func (s *session) save() error {
+	if s.logFile == "" {
+		return nil
+	}
+	return bisect.SaveBisectLog(s.logFile, s.log())
+}
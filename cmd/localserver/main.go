@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+)
+
+func main() {
+	var addr = flag.String("addr", "localhost:1234", "address to serve the problems on")
+	var tests = flag.String("tests", "tests/*.json", "glob of the test problems to serve")
+	flag.Parse()
+
+	cases, err := bisect.LoadTestCases(*tests)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Serving %v problems on ws://%v/ 🤖\n", len(cases), *addr)
+
+	http.Handle("/", bisect.NewServer(cases))
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/config"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [-problem tests/tiny-chain-3.json | -repo <repo>] [flags] <bisect log>
+
+Replays a bisect log (written by fromwebsockets -bisect-logs) on a fresh DAG of the problem, like git bisect replay,
+and prints the first bad commit or the next question. Use -undo to take back the last few answers first,
+and -continue to carry on from there with the problem's own answers.
+Without -problem or -repo the DAG the log refers to is used, which -bisect-logs saves next to the logs,
+so the logs of problems from the server can be replayed too.
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var problem = flag.String("problem", "", "test problem the log is for, e.g. tests/tiny-chain-3.json")
+	var repo = flag.String("repo", "", "git repository, Repo message or saved DAG the log is for, when there is no test problem")
+	var undo = flag.Int("undo", 0, "number of answers to undo before carrying on")
+	var carryOn = flag.Bool("continue", false, "carry on bisecting, answering with the problem's bug")
+	var out = flag.String("out", "", "file to write the resulting log to")
+	var why = flag.String("why", "", "commit to explain, whether it is still a candidate and if not why not")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 || (*problem != "" && *repo != "") {
+		usage()
+		os.Exit(2)
+	}
+
+	l, err := bisect.LoadBisectLog(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, t, err := load(*problem, *repo, flag.Arg(0), l)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *carryOn && t == nil {
+		log.Fatal("-continue needs -problem, to answer the questions")
+	}
+
+	err = l.Undo(*undo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := bisect.Replay(p, l)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Replayed %v answers, %v candidates left\n", len(l.Answers), c.Len())
+
+	pc := config.Default().Params
+	if *carryOn {
+		_, questions, err := bisect.Bisect(context.Background(), c, pc, bisect.NewLocalOracle(t))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Carried on for %v more questions\n", questions)
+		l.Update(c)
+	}
+
+	if *why != "" {
+		explanation, err := c.Explain(*why)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(explanation)
+	}
+
+	if c.View().GetEligibleOrder() == 0 {
+		fmt.Printf("First bad commit: %v\n", c.MostRecentBad())
+		if t != nil && c.MostRecentBad() != t.Bug {
+			fmt.Printf("That's wrong, the bug is %v\n", t.Bug)
+		}
+	} else {
+		next, err := c.View().GetMidPointSkipping(context.Background(), pc, l.Skipped())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Next question: %v\n", next)
+	}
+
+	if *out != "" {
+		err = bisect.SaveBisectLog(*out, l)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// load gets the problem the log is for, from the test problem, the repo or else the DAG the log refers to
+// The test case is only there with a test problem.
+func load(problem, repo, logPath string, l *bisect.BisectLog) (bisect.ProblemInstance, *bisect.TestCase, error) {
+	switch {
+	case problem != "":
+		t, err := bisect.LoadTestCase(problem)
+		if err != nil {
+			return bisect.ProblemInstance{}, nil, err
+		}
+		return t.Problem, t, nil
+	case repo == "":
+		p, err := bisect.LoadLogDAG(logPath, l)
+		return p, nil, err
+	}
+
+	info, err := os.Stat(repo)
+	if err != nil {
+		return bisect.ProblemInstance{}, nil, err
+	}
+	if info.IsDir() {
+		r, err := bisect.RepoFromGit(repo, l.Bad)
+		return bisect.ProblemInstance{Repo: r}, nil, err
+	}
+
+	p, err := bisect.LoadProblem(repo)
+	if err != nil {
+		return p, nil, err
+	}
+	if p.DAG != nil {
+		// A saved DAG is named after its file, which needn't be the repo's name
+		p.Repo.Name = l.Repo
+	}
+	return p, nil, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+)
+
+// result is how well a single ParamConfig did over the whole corpus
+type result struct {
+	Config    dag.ParamConfig
+	Questions int
+	Wrong     int
+	Duration  time.Duration
+	Cost      float64
+}
+
+func main() {
+	var tests = flag.String("tests", "tests/*.json", "glob of the test problems to tune against")
+	var n = flag.Int("n", 0, "only use the first n test problems (0 = all of them)")
+	var limits = flag.String("limits", "1000,5000,10000", "comma separated Limit values to try")
+	var divisions = flag.String("divisions", "10,50,100", "comma separated Divisions values to try")
+	var merges = flag.String("merges", "10,50,100", "comma separated Merges values to try")
+	var weight = flag.Float64("time-weight", 1, "how many questions one second of wall time is worth")
+	var out = flag.String("out", "paramconfig.json", "file to write the best configuration to")
+	var verbose = flag.Bool("v", false, "keep the solver logging")
+	flag.Parse()
+
+	cases, err := bisect.LoadTestCases(*tests)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *n > 0 && *n < len(cases) {
+		cases = cases[:*n]
+	}
+	if len(cases) == 0 {
+		log.Fatalf("No test problems match %v", *tests)
+	}
+
+	configs, err := grid(*limits, *divisions, *merges)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Tuning %v configurations over %v problems 🔧\n", len(configs), len(cases))
+
+	// The solver is chatty, so only the tuner gets to talk
+	logger := log.New(os.Stderr, log.Prefix(), log.Flags())
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	var best *result
+	for _, config := range configs {
+		r, err := evaluate(config, cases, *weight)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		logger.Printf("%+v: %v questions, %v wrong, took %v (cost %.2f)\n", r.Config, r.Questions, r.Wrong, r.Duration, r.Cost)
+
+		if r.Wrong > 0 {
+			continue
+		}
+		if best == nil || r.Cost < best.Cost {
+			best = r
+		}
+	}
+
+	if best == nil {
+		logger.Fatal("Every configuration got something wrong 😢")
+	}
+
+	logger.Printf("Best: %+v with %v questions in %v ✅\n", best.Config, best.Questions, best.Duration)
+
+	err = dag.SaveParamConfig(*out, best.Config)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// evaluate solves every test case with the config, the cost is the average questions plus the weighted average seconds
+func evaluate(config dag.ParamConfig, cases []*bisect.TestCase, weight float64) (*result, error) {
+	r := &result{Config: config}
+
+	start := time.Now()
+	for _, t := range cases {
+		solution, questions, err := bisect.SolveTestCase(context.Background(), t, config)
+		if err != nil {
+			return nil, err
+		}
+		if solution.Solution != t.Bug {
+			r.Wrong++
+		}
+		r.Questions += questions
+	}
+	r.Duration = time.Since(start)
+
+	count := float64(len(cases))
+	r.Cost = float64(r.Questions)/count + weight*r.Duration.Seconds()/count
+
+	return r, nil
+}
+
+// grid returns every combination of the comma separated values
+func grid(limits, divisions, merges string) ([]dag.ParamConfig, error) {
+	ls, err := parseInts(limits)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := parseInts(divisions)
+	if err != nil {
+		return nil, err
+	}
+	ms, err := parseInts(merges)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []dag.ParamConfig
+	for _, l := range ls {
+		for _, d := range ds {
+			if d <= 0 {
+				return nil, fmt.Errorf("divisions must be positive, got %v", d)
+			}
+			for _, m := range ms {
+				configs = append(configs, dag.ParamConfig{
+					Limit:     l,
+					Divisions: d,
+					Merges:    m,
+				})
+			}
+		}
+	}
+
+	return configs, nil
+}
+
+func parseInts(s string) ([]int, error) {
+	var ints []int
+	for _, field := range strings.Split(s, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
@@ -1,5 +1,5 @@
 module github.com/jamesjarvis/git-bisect
 
-go 1.13
+go 1.18
 
 require github.com/gorilla/websocket v1.4.1
@@ -0,0 +1,187 @@
+package bisect
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+)
+
+// BatchOracle can answer several questions in one go, like the local server with Questions
+type BatchOracle interface {
+	AskBatch(ctx context.Context, qs []Question) ([]Answer, error)
+}
+
+// AskBatch lets the websocket connection be used as a BatchOracle, against the local server
+func (c *Connection) AskBatch(ctx context.Context, qs []Question) ([]Answer, error) {
+	batch := Questions{Questions: make([]string, len(qs))}
+	for i, q := range qs {
+		batch.Questions[i] = q.Question
+	}
+
+	answers, err := c.AskQuestionsWebsocket(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Answer, len(answers.Answers))
+	for i, a := range answers.Answers {
+		result[i] = Answer{Answer: a}
+	}
+	return result, nil
+}
+
+// answered is what came back for some of the questions in flight, or the error asking them
+type answered struct {
+	questions []Question
+	answers   []Answer
+	err       error
+}
+
+// BisectBatch is Bisect with up to k questions in flight at once, for when there are spare machines to test on
+// The questions are picked with GetMidPoints so their answers together split the candidates as finely as possible.
+// A BatchOracle gets them all in one go, and any other Oracle gets each one on its own goroutine, so it must be
+// safe to use concurrently. Answers are applied as they arrive, and the questions in flight are topped back up to k.
+// An answer about a commit that was ruled out while we waited for it is dropped, as it can't tell us anything more.
+// The number of questions returned is every question asked, including any that were dropped.
+// With k of 1 or less it is just Bisect.
+func BisectBatch(ctx context.Context, c *dag.Candidates, pc dag.ParamConfig, o Oracle, k int) (Solution, int, error) {
+	if k <= 1 {
+		return Bisect(ctx, c, pc, o)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	results := make(chan answered, k)
+	inflight := make(map[string]bool)
+	running := 0
+	questions := 0
+
+	// Nothing is left asking the oracle once we return, which matters for a websocket that is used for the next problem
+	defer func() {
+		cancel()
+		for ; running > 0; running-- {
+			<-results
+		}
+	}()
+
+	dispatch := func(qs []Question) {
+		for _, q := range qs {
+			inflight[q.Question] = true
+			log.Printf("❓Asking about %v (%v in flight)\n", q.Question, len(inflight))
+		}
+		questions += len(qs)
+
+		if batch, ok := o.(BatchOracle); ok {
+			running++
+			go func() {
+				answers, err := batch.AskBatch(ctx, qs)
+				if err == nil && len(answers) != len(qs) {
+					err = fmt.Errorf("asked %v questions, but got %v answers", len(qs), len(answers))
+				}
+				results <- answered{qs, answers, err}
+			}()
+			return
+		}
+
+		for _, q := range qs {
+			q := q
+			running++
+			go func() {
+				answer, err := o.Ask(ctx, q)
+				results <- answered{[]Question{q}, []Answer{answer}, err}
+			}()
+		}
+	}
+
+	apply := func(r answered) error {
+		running--
+		if r.err != nil {
+			return r.err
+		}
+
+		for i, q := range r.questions {
+			delete(inflight, q.Question)
+
+			if status, err := c.Status(q.Question); err != nil {
+				return err
+			} else if status != dag.Candidate {
+				log.Printf("🗑️ Dropping the answer about %v, it was %v while we waited\n", q.Question, status)
+				continue
+			}
+
+			switch r.answers[i].Answer {
+			case "Good":
+				err := c.Good(q.Question)
+				if err != nil {
+					return err
+				}
+				log.Printf("Now %v commits after GOOD 👍 (%v)\n", c.View().GetOrder(), q.Question)
+			case "Bad":
+				err := c.Bad(q.Question)
+				if err != nil {
+					return err
+				}
+				log.Printf("Now %v commits after BAD 👎 (%v)\n", c.View().GetOrder(), q.Question)
+			}
+		}
+		return nil
+	}
+
+	for c.View().GetEligibleOrder() > 0 {
+		if err := ctx.Err(); err != nil {
+			return Solution{}, questions, err
+		}
+
+		if len(inflight) < k {
+			pending := make([]string, 0, len(inflight))
+			for q := range inflight {
+				pending = append(pending, q)
+			}
+
+			next, err := c.View().GetMidPoints(ctx, pc, k-len(inflight), pending)
+			if err != nil {
+				return Solution{}, questions, err
+			}
+			if len(next) == 0 && len(inflight) == 0 {
+				return Solution{}, questions, UntestableError{Candidates: c.Len()}
+			}
+
+			if len(next) > 0 {
+				if estimate, err := c.View().GetEstimate(next[0]); err == nil {
+					log.Printf("📏 %v candidates left, %v", estimate.Candidates, estimate)
+				}
+				qs := make([]Question, len(next))
+				for i, q := range next {
+					qs[i] = Question{Question: q}
+				}
+				dispatch(qs)
+			}
+		}
+
+		// Wait for an answer, and take any others that have come in at the same time before asking more
+		select {
+		case r := <-results:
+			if err := apply(r); err != nil {
+				return Solution{}, questions, err
+			}
+		case <-ctx.Done():
+			return Solution{}, questions, ctx.Err()
+		}
+	drain:
+		for {
+			select {
+			case r := <-results:
+				if err := apply(r); err != nil {
+					return Solution{}, questions, err
+				}
+			default:
+				break drain
+			}
+		}
+	}
+
+	return Solution{
+		Solution: c.MostRecentBad(),
+	}, questions, nil
+}
@@ -0,0 +1,306 @@
+package bisect_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/gen"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+// slowOracle answers each question after a random delay, so the answers come back in any order
+type slowOracle struct {
+	o *bisect.LocalOracle
+
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (s *slowOracle) Ask(ctx context.Context, q bisect.Question) (bisect.Answer, error) {
+	s.mu.Lock()
+	delay := time.Duration(s.r.Intn(500)) * time.Microsecond
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+	return s.o.Ask(ctx, q)
+}
+
+// batchOracle answers all of the questions at once
+type batchOracle struct {
+	*bisect.LocalOracle
+	batches int
+}
+
+func (b *batchOracle) AskBatch(ctx context.Context, qs []bisect.Question) ([]bisect.Answer, error) {
+	b.batches++
+	answers := make([]bisect.Answer, len(qs))
+	for i, q := range qs {
+		answers[i], _ = b.Ask(ctx, q)
+	}
+	return answers, nil
+}
+
+func TestBisectBatch(t *testing.T) {
+	for _, family := range gen.Names() {
+		for seed := int64(0); seed < 3; seed++ {
+			name := fmt.Sprintf("small-%v-%v", family, seed)
+			commits, _ := gen.ParseSize("small")
+			c := gen.TestCase(gen.Families[family], name, commits, seed)
+
+			t.Run(name, func(t *testing.T) {
+				for _, k := range []int{1, 2, 4} {
+					oracles := map[string]bisect.Oracle{
+						"concurrent": &slowOracle{o: bisect.NewLocalOracle(c), r: rand.New(rand.NewSource(seed))},
+						"batched":    &batchOracle{LocalOracle: bisect.NewLocalOracle(c)},
+					}
+					for kind, o := range oracles {
+						cands, err := bisect.PrepareCandidates(c.Problem)
+						if err != nil {
+							t.Fatal(err)
+						}
+						solution, questions, err := bisect.BisectBatch(context.Background(), cands, params, o, k)
+						if err != nil {
+							t.Fatalf("%v k=%v: %v", kind, k, err)
+						}
+						if solution.Solution != c.Bug {
+							t.Fatalf("%v k=%v: found %v, but the bug is %v", kind, k, solution.Solution, c.Bug)
+						}
+						if questions > len(c.Problem.Repo.Dag) {
+							t.Fatalf("%v k=%v: asked %v questions about %v commits", kind, k, questions, len(c.Problem.Repo.Dag))
+						}
+						if b, ok := o.(*batchOracle); ok && k > 1 && b.batches > questions {
+							t.Fatalf("%v k=%v: %v batches for %v questions", kind, k, b.batches, questions)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// With more questions at once there should be fewer rounds of asking
+// Ties between midpoints can go either way, so this is over a few problems rather than hoping one is typical.
+func TestBisectBatchRounds(t *testing.T) {
+	rounds := make(map[int]int)
+	for seed := int64(0); seed < 5; seed++ {
+		c := gen.TestCase(gen.Chain, fmt.Sprintf("medium-chain-%v", seed), 1000, seed)
+
+		for _, k := range []int{2, 4} {
+			cands, err := bisect.PrepareCandidates(c.Problem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := &batchOracle{LocalOracle: bisect.NewLocalOracle(c)}
+			solution, _, err := bisect.BisectBatch(context.Background(), cands, params, o, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if solution.Solution != c.Bug {
+				t.Fatalf("%v k=%v: found %v, but the bug is %v", c.Problem.Repo.Name, k, solution.Solution, c.Bug)
+			}
+			rounds[k] += o.batches
+		}
+	}
+	if rounds[4] >= rounds[2] {
+		t.Errorf("took %v rounds with 4 at once and %v with 2", rounds[4], rounds[2])
+	}
+}
+
+func TestLocalServerBatch(t *testing.T) {
+	var cases []*bisect.TestCase
+	for seed := int64(0); seed < 3; seed++ {
+		cases = append(cases, gen.TestCase(gen.Random, fmt.Sprintf("small-random-%v", seed), 50, seed))
+	}
+	server := httptest.NewServer(bisect.NewServer(cases))
+	defer server.Close()
+
+	u := url.URL{Scheme: "ws", Host: strings.TrimPrefix(server.URL, "http://"), Path: "/"}
+	conn, err := bisect.ConnectWebsocket(context.Background(), u, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Batch = 3
+
+	score, err := conn.Solve(context.Background(), bisect.Authentication{User: []string{"user", "token"}}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if result := score.Score[c.Problem.Repo.Name]; result.Outcome != report.Correct {
+			t.Errorf("%v: got %+v", c.Problem.Repo.Name, result)
+		}
+	}
+}
+
+// instances makes a repo with two instances, which have different good commits
+func instances(t *testing.T) []*bisect.TestCase {
+	first := gen.TestCase(gen.Random, "small-random", 50, 0)
+	first.Problem.Repo.InstanceCount = 2
+	second := *first
+	ancestors, err := bisect.DAGMaker(&first.Problem.Repo).GetOrderedAncestors(first.Bug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range ancestors {
+		if v != first.Problem.Instance.Good {
+			second.Problem.Instance.Good = v
+			break
+		}
+	}
+	return []*bisect.TestCase{first, &second}
+}
+
+// checkInstances checks the repo is reported once, for all of its instances, like the server scores it
+func checkInstances(t *testing.T, cases []*bisect.TestCase, score bisect.Score) {
+	var want dag.Bound
+	for _, c := range cases {
+		cands, err := bisect.PrepareCandidates(c.Problem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bound := cands.View().GetBound(dag.ExactLimit)
+		want.Lower += bound.Lower
+		want.Optimal += bound.Optimal
+	}
+
+	problems := score.Problems()
+	if len(problems) != 1 {
+		t.Fatalf("got problems %+v, want just the repo", problems)
+	}
+	p := problems[0]
+	if p.Name != "small-random" || p.Outcome != report.Correct {
+		t.Errorf("got %+v", p)
+	}
+	if !p.Bounded || !p.Exact || p.Optimal != want.Optimal {
+		t.Errorf("got bound %v (bounded %v, exact %v), want %v for both instances", p.Optimal, p.Bounded, p.Exact, want.Optimal)
+	}
+	if p.Questions < want.Optimal {
+		t.Errorf("solved both instances with %v questions, fewer than the optimal %v", p.Questions, want.Optimal)
+	}
+}
+
+// The instances of a repo are scored and bounded together under its name, rather than overwriting each other
+func TestLocalServerInstances(t *testing.T) {
+	cases := instances(t)
+	server := httptest.NewServer(bisect.NewServer(cases))
+	defer server.Close()
+
+	u := url.URL{Scheme: "ws", Host: strings.TrimPrefix(server.URL, "http://"), Path: "/"}
+	conn, err := bisect.ConnectWebsocket(context.Background(), u, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	score, err := conn.Solve(context.Background(), bisect.Authentication{User: []string{"user", "token"}}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInstances(t, cases, score)
+}
+
+// With a worker for each instance, the workers' scores are added together
+func TestSolveLocalPoolInstances(t *testing.T) {
+	cases := instances(t)
+	score, err := bisect.SolveLocalPool(context.Background(), 2, cases, params, bisect.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInstances(t, cases, score)
+}
+
+func TestMergeScores(t *testing.T) {
+	correct := func(questions int) bisect.Score {
+		return bisect.Score{
+			Score:  map[string]bisect.ProblemResult{"pb0": {Outcome: report.Correct, Questions: questions}},
+			Bounds: map[string]dag.Bound{"pb0": {Lower: 2, Optimal: 3, Exact: true}},
+		}
+	}
+	wrong := bisect.Score{
+		Score:  map[string]bisect.ProblemResult{"pb0": {Outcome: report.Wrong}},
+		Bounds: map[string]dag.Bound{"pb0": {Lower: 4}},
+	}
+
+	merged := bisect.MergeScores(correct(5), correct(7))
+	if result := merged.Score["pb0"]; result != (bisect.ProblemResult{Outcome: report.Correct, Questions: 12}) {
+		t.Errorf("two correct instances are %+v, want 12 questions", result)
+	}
+	if bound := merged.Bounds["pb0"]; bound != (dag.Bound{Lower: 4, Optimal: 6, Exact: true}) {
+		t.Errorf("got bound %+v", bound)
+	}
+
+	merged = bisect.MergeScores(correct(5), wrong, correct(7))
+	if result := merged.Score["pb0"]; result.Outcome != report.Wrong {
+		t.Errorf("with a wrong instance got %+v", result)
+	}
+	if bound := merged.Bounds["pb0"]; bound.Exact || bound.Best() != 8 {
+		t.Errorf("with an inexact bound got %+v", bound)
+	}
+}
+
+// A problem where nothing left can be tested gets the most recent bad as a guess, and the problems after it are still solved
+func TestUntestableProblem(t *testing.T) {
+	untestable := gen.TestCase(gen.Chain, "tiny-chain-0", 20, 1)
+	solvable := gen.TestCase(gen.Random, "small-random", 50, 0)
+	cases := []*bisect.TestCase{untestable, solvable}
+
+	costs := dag.Costs{}
+	for _, e := range untestable.Problem.Repo.Dag {
+		costs[e.Commit] = dag.Untestable
+	}
+	for _, e := range solvable.Problem.Repo.Dag {
+		delete(costs, e.Commit)
+	}
+	want := report.Wrong
+	if untestable.Bug == untestable.Problem.Instance.Bad {
+		want = report.Correct
+	}
+
+	server := httptest.NewServer(bisect.NewServer(cases))
+	defer server.Close()
+	u := url.URL{Scheme: "ws", Host: strings.TrimPrefix(server.URL, "http://"), Path: "/"}
+	conn, err := bisect.ConnectWebsocket(context.Background(), u, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Options = bisect.Options{Costs: costs}
+	remote, err := conn.Solve(context.Background(), bisect.Authentication{User: []string{"user", "token"}}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := bisect.SolveLocalPool(context.Background(), 1, cases, params, bisect.Options{Costs: costs, Batch: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for how, score := range map[string]bisect.Score{"server": remote, "local batch": local} {
+		if result := score.Score["tiny-chain-0"]; result.Outcome != want || result.Questions != 0 {
+			t.Errorf("%v: got %+v for the untestable problem, want %v without asking anything", how, result, want)
+		}
+		if result := score.Score["small-random"]; result.Outcome != report.Correct {
+			t.Errorf("%v: got %+v for the problem after it", how, result)
+		}
+	}
+
+	c, err := bisect.PrepareCandidates(untestable.Problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetCosts(costs)
+	_, _, err = bisect.Bisect(context.Background(), c, params, bisect.NewLocalOracle(untestable))
+	if _, ok := err.(bisect.UntestableError); !ok {
+		t.Errorf("got error %v, want an UntestableError", err)
+	}
+}
@@ -0,0 +1,289 @@
+package bisect
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+)
+
+// BisectLog is every question answered for an instance, like git bisect log, so a run can be replayed exactly.
+// The text form mirrors git's, with a line for the repo, then the instance and every answer in order:
+//
+//	# git-bisect log
+//	repo tiny-chain-3
+//	dag tiny-chain-3.dag
+//	start <bad sha> <good sha>
+//	only <sha>
+//	skip <sha>
+//	good <sha>
+//	bad <sha>
+//	# first bad commit: <sha>
+//
+// The dag line is the file the repo's DAG was saved to (relative to the log), for problems that only came from the server.
+// There is an only line for each commit the bisection was limited to (see dag.Candidates.SetOnly), if it was,
+// and a skip line for each commit that couldn't be tested, like git bisect skip, which are only avoided as questions.
+// Blank lines and other comments are ignored, and the first bad commit is only there once there is nothing left to ask.
+type BisectLog struct {
+	Repo     string
+	DAG      string
+	Good     string
+	Bad      string
+	Only     []string
+	Skips    []string
+	Answers  []dag.Answer
+	Solution string
+}
+
+// firstBad is the comment that records the solution, as git writes it
+const firstBad = "# first bad commit: "
+
+// NewBisectLog is the log of the candidates for the problem, which were made by PrepareCandidates
+// The first two answers of the candidates are the instance's good and bad commits, so they are left out.
+func NewBisectLog(problem ProblemInstance, c *dag.Candidates) *BisectLog {
+	l := &BisectLog{
+		Repo: problem.Repo.Name,
+		Good: problem.Instance.Good,
+		Bad:  problem.Instance.Bad,
+	}
+	if only := c.View().GetOnly(); only != nil {
+		l.Only = make([]string, 0, len(only))
+		for v := range only {
+			l.Only = append(l.Only, v)
+		}
+		sort.Strings(l.Only)
+	}
+	if answers := c.Answers(); len(answers) > 2 {
+		l.Answers = answers[2:]
+	}
+	if c.View().GetEligibleOrder() == 0 {
+		l.Solution = c.MostRecentBad()
+	}
+	return l
+}
+
+// Undo drops the last n answers, along with the solution they led to
+func (l *BisectLog) Undo(n int) error {
+	if n < 0 || n > len(l.Answers) {
+		return fmt.Errorf("can't undo %v answers, the log only has %v", n, len(l.Answers))
+	}
+	l.Answers = l.Answers[:len(l.Answers)-n]
+	l.Solution = ""
+	return nil
+}
+
+// Update brings the log up to date with candidates that Replay made from it and that have been answered since,
+// keeping the log's own start, repo, DAG and skips rather than those of whatever problem it was replayed on
+func (l *BisectLog) Update(c *dag.Candidates) {
+	problem := ProblemInstance{Repo: Repo{Name: l.Repo}, Instance: Instance{Good: l.Good, Bad: l.Bad}}
+	updated := NewBisectLog(problem, c)
+	updated.DAG = l.DAG
+	updated.Skips = l.Skips
+	*l = *updated
+}
+
+// Skip records the commits that couldn't be tested, in order so the log is always written the same way
+func (l *BisectLog) Skip(skip map[string]bool) {
+	l.Skips = nil
+	for v := range skip {
+		l.Skips = append(l.Skips, v)
+	}
+	sort.Strings(l.Skips)
+}
+
+// Skipped is the skipped commits, for GetMidPointSkipping
+func (l *BisectLog) Skipped() map[string]bool {
+	skip := make(map[string]bool, len(l.Skips))
+	for _, v := range l.Skips {
+		skip[v] = true
+	}
+	return skip
+}
+
+// String is the text form of the log
+func (l *BisectLog) String() string {
+	var b strings.Builder
+	b.WriteString("# git-bisect log\n")
+	fmt.Fprintf(&b, "repo %s\n", l.Repo)
+	if l.DAG != "" {
+		fmt.Fprintf(&b, "dag %s\n", l.DAG)
+	}
+	fmt.Fprintf(&b, "start %s %s\n", l.Bad, l.Good)
+	for _, v := range l.Only {
+		fmt.Fprintf(&b, "only %s\n", v)
+	}
+	for _, v := range l.Skips {
+		fmt.Fprintf(&b, "skip %s\n", v)
+	}
+	for _, a := range l.Answers {
+		fmt.Fprintf(&b, "%s\n", a)
+	}
+	if l.Solution != "" {
+		fmt.Fprintf(&b, "%s%s\n", firstBad, l.Solution)
+	}
+	return b.String()
+}
+
+// ReadBisectLog reads the text form of a log
+func ReadBisectLog(r io.Reader) (*BisectLog, error) {
+	l := &BisectLog{}
+	started := false
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, firstBad) {
+			l.Solution = strings.TrimSpace(strings.TrimPrefix(line, firstBad))
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "repo" && len(fields) == 2:
+			l.Repo = fields[1]
+		case fields[0] == "dag" && len(fields) == 2:
+			l.DAG = fields[1]
+		case fields[0] == "start" && len(fields) == 3 && !started:
+			l.Bad, l.Good = fields[1], fields[2]
+			started = true
+		case fields[0] == "only" && len(fields) == 2 && started:
+			l.Only = append(l.Only, fields[1])
+		case fields[0] == "skip" && len(fields) == 2 && started:
+			l.Skips = append(l.Skips, fields[1])
+		case (fields[0] == "good" || fields[0] == "bad") && len(fields) == 2 && started:
+			l.Answers = append(l.Answers, dag.Answer{Commit: fields[1], Good: fields[0] == "good"})
+		case !started && (fields[0] == "good" || fields[0] == "bad" || fields[0] == "skip"):
+			return nil, fmt.Errorf("line %v: answer before the start", n)
+		default:
+			return nil, fmt.Errorf("line %v: can't understand '%s'", n, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !started {
+		return nil, fmt.Errorf("the log has no start")
+	}
+	return l, nil
+}
+
+// SaveBisectLog writes the log to the file
+func SaveBisectLog(path string, l *BisectLog) error {
+	return ioutil.WriteFile(path, []byte(l.String()), 0644)
+}
+
+// LoadBisectLog reads a log written by SaveBisectLog
+func LoadBisectLog(path string) (*BisectLog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l, err := ReadBisectLog(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Replay applies the log to a fresh DAG of the problem's repo, like git bisect replay
+// The log's start is used rather than the problem's instance. If the log has a solution, the replay has to find it too.
+// Skips don't change the candidates, so they are left to whatever asks the next question (see Skipped).
+func Replay(problem ProblemInstance, l *BisectLog) (*dag.Candidates, error) {
+	if l.Repo != "" && l.Repo != problem.Repo.Name {
+		return nil, fmt.Errorf("the log is for %v, not %v", l.Repo, problem.Repo.Name)
+	}
+
+	problem.Instance = Instance{Good: l.Good, Bad: l.Bad}
+	c, err := PrepareCandidates(problem)
+	if err != nil {
+		return nil, err
+	}
+	if l.Only != nil {
+		only := make(map[string]bool, len(l.Only))
+		for _, v := range l.Only {
+			only[v] = true
+		}
+		c.SetOnly(only)
+	}
+
+	for i, a := range l.Answers {
+		err = c.Answer(a)
+		if err != nil {
+			return nil, fmt.Errorf("answer %v (%v): %v", i+1, a, err)
+		}
+	}
+
+	if l.Solution != "" {
+		if c.View().GetEligibleOrder() > 0 {
+			return nil, fmt.Errorf("the log says %v is the first bad commit, but there are still %v candidates", l.Solution, c.Len())
+		}
+		if c.MostRecentBad() != l.Solution {
+			return nil, fmt.Errorf("the log says %v is the first bad commit, but the replay found %v", l.Solution, c.MostRecentBad())
+		}
+	}
+	return c, nil
+}
+
+// LoadLogDAG loads the DAG the log refers to, for replaying it without the problem, with the path of the log
+// that the reference is relative to. The problem has no Instance, Replay uses the log's start.
+func LoadLogDAG(logPath string, l *BisectLog) (ProblemInstance, error) {
+	if l.DAG == "" {
+		return ProblemInstance{}, fmt.Errorf("%s doesn't say where its DAG is", logPath)
+	}
+	problem, err := LoadProblem(filepath.Join(filepath.Dir(logPath), l.DAG))
+	if err != nil {
+		return problem, err
+	}
+	problem.Repo.Name = l.Repo
+	return problem, nil
+}
+
+// saveBisectLog writes the log of the instance to the directory, if there is one
+// The repo's DAG is saved next to the logs the first time it is seen (saved is the repos already done),
+// so the logs of problems from the server can be replayed too.
+// Like the cache it only logs any error, as the logs are just for looking at afterwards.
+func saveBisectLog(dir string, problem ProblemInstance, c *dag.Candidates, saved map[string]bool) {
+	if dir == "" || c == nil {
+		return
+	}
+	l := NewBisectLog(problem, c)
+
+	dagName := unsafeName.ReplaceAllString(problem.Repo.Name+".dag", "_")
+	if !saved[problem.Repo.Name] {
+		d := problem.DAG
+		if d == nil {
+			d = DAGMaker(&problem.Repo)
+		}
+		err := d.Save(filepath.Join(dir, dagName))
+		if err != nil {
+			log.Printf("Couldn't save the DAG of %v with its bisect logs: %v", problem.Repo.Name, err)
+		} else {
+			saved[problem.Repo.Name] = true
+		}
+	}
+	if saved[problem.Repo.Name] {
+		l.DAG = dagName
+	}
+
+	// Instances of the same repo can share a bad commit, but not the good one as well
+	name := fmt.Sprintf("%s-%.8s-%.8s.log", problem.Repo.Name, problem.Instance.Bad, problem.Instance.Good)
+	path := filepath.Join(dir, unsafeName.ReplaceAllString(name, "_"))
+
+	err := SaveBisectLog(path, l)
+	if err != nil {
+		log.Printf("Couldn't save the bisect log of %v: %v", problem.Repo.Name, err)
+	}
+}
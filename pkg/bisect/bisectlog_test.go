@@ -0,0 +1,253 @@
+package bisect_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/gen"
+)
+
+// solveWithLog solves the test case, returning its bisect log
+func solveWithLog(t *testing.T, tc *bisect.TestCase, pc dag.ParamConfig) *bisect.BisectLog {
+	c, err := bisect.PrepareCandidates(tc.Problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = bisect.Bisect(context.Background(), c, pc, bisect.NewLocalOracle(tc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bisect.NewBisectLog(tc.Problem, c)
+}
+
+func TestBisectLogReplay(t *testing.T) {
+	for _, family := range gen.Names() {
+		for seed := int64(0); seed < 3; seed++ {
+			tc := gen.TestCase(gen.Families[family], "small-"+family, 200, seed)
+			l := solveWithLog(t, tc, params)
+			if l.Solution != tc.Bug {
+				t.Fatalf("%v: the log says %v, want %v", tc.Problem.Repo.Name, l.Solution, tc.Bug)
+			}
+
+			// The text form reads back the same
+			read, err := bisect.ReadBisectLog(strings.NewReader(l.String()))
+			if err != nil {
+				t.Fatalf("%v: %v\n%v", tc.Problem.Repo.Name, err, l)
+			}
+			if !reflect.DeepEqual(read, l) {
+				t.Fatalf("%v: read back %+v, want %+v", tc.Problem.Repo.Name, read, l)
+			}
+
+			// Replaying gets to the same place
+			c, err := bisect.Replay(tc.Problem, read)
+			if err != nil {
+				t.Fatalf("%v: %v", tc.Problem.Repo.Name, err)
+			}
+			if c.MostRecentBad() != tc.Bug || c.Len() != 1 {
+				t.Errorf("%v: replay found %v with %v candidates", tc.Problem.Repo.Name, c.MostRecentBad(), c.Len())
+			}
+
+			// Undoing some answers and carrying on finds the same bug, though ties between midpoints mean the questions can differ
+			n := len(l.Answers) / 2
+			undone := *l
+			if err := undone.Undo(n); err != nil {
+				t.Fatal(err)
+			}
+			c, err = bisect.Replay(tc.Problem, &undone)
+			if err != nil {
+				t.Fatalf("%v: %v", tc.Problem.Repo.Name, err)
+			}
+			_, _, err = bisect.Bisect(context.Background(), c, params, bisect.NewLocalOracle(tc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			again := undone
+			again.Update(c)
+			kept := l.Answers[:len(l.Answers)-n]
+			if again.Solution != tc.Bug || !reflect.DeepEqual(again.Answers[:len(kept)], kept) {
+				t.Errorf("%v: carrying on after undoing %v answers gave\n%v\nwant\n%v", tc.Problem.Repo.Name, n, &again, l)
+			}
+		}
+	}
+}
+
+// The logs saved while solving refer to the DAG saved next to them, so they replay without the problems
+func TestReplaySavedLogs(t *testing.T) {
+	dir := t.TempDir()
+	var cases []*bisect.TestCase
+	for _, family := range gen.Names() {
+		cases = append(cases, gen.TestCase(gen.Families[family], "small-"+family, 200, 1))
+	}
+	// Another instance of the same repo with the same bad commit still gets a log of its own
+	again := *cases[0]
+	ancestors, err := bisect.DAGMaker(&again.Problem.Repo).GetOrderedAncestors(again.Bug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range ancestors {
+		if v != again.Problem.Instance.Good {
+			again.Problem.Instance.Good = v
+			break
+		}
+	}
+	cases = append(cases, &again)
+
+	_, err = bisect.SolveLocalPool(context.Background(), 1, cases, params, bisect.Options{Logs: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != len(cases) {
+		t.Fatalf("%v logs were saved for %v problems", len(logs), len(cases))
+	}
+	for _, path := range logs {
+		l, err := bisect.LoadBisectLog(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		problem, err := bisect.LoadLogDAG(path, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := bisect.Replay(problem, l)
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		if c.MostRecentBad() != l.Solution {
+			t.Errorf("%v: replay found %v, want %v", path, c.MostRecentBad(), l.Solution)
+		}
+	}
+
+	if _, err := bisect.LoadLogDAG("x.log", &bisect.BisectLog{Repo: "x"}); err == nil {
+		t.Error("no error loading the DAG of a log that doesn't have one")
+	}
+}
+
+// Carrying on from a log keeps its start, even when the problem it is replayed on has a different instance
+func TestUpdateKeepsStart(t *testing.T) {
+	tc := gen.TestCase(gen.Chain, "tiny-chain-0", 20, 1)
+	l := solveWithLog(t, tc, params)
+	l.DAG = "tiny-chain-0.dag"
+	if err := l.Undo(len(l.Answers)); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := tc.Problem
+	moved.Instance = bisect.Instance{Good: "somewhere", Bad: "else"}
+	c, err := bisect.Replay(moved, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = bisect.Bisect(context.Background(), c, params, bisect.NewLocalOracle(tc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Update(c)
+	if l.Good != tc.Problem.Instance.Good || l.Bad != tc.Problem.Instance.Bad || l.DAG != "tiny-chain-0.dag" {
+		t.Errorf("the log now starts at %v %v with DAG %v", l.Bad, l.Good, l.DAG)
+	}
+	if l.Solution != tc.Bug || len(l.Answers) == 0 {
+		t.Errorf("the log found %v with %v answers, want %v", l.Solution, len(l.Answers), tc.Bug)
+	}
+}
+
+// Skips are written like git bisect skip, and kept when carrying on
+func TestBisectLogSkips(t *testing.T) {
+	tc := gen.TestCase(gen.Chain, "tiny-chain-0", 20, 1)
+	l := solveWithLog(t, tc, params)
+	if err := l.Undo(len(l.Answers)); err != nil {
+		t.Fatal(err)
+	}
+	skip := map[string]bool{l.Bad: true, l.Good: true}
+	l.Skip(skip)
+	if !strings.Contains(l.String(), "skip "+l.Bad+"\n") {
+		t.Errorf("no skip line in\n%v", l)
+	}
+
+	read, err := bisect.ReadBisectLog(strings.NewReader(l.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Skipped(), skip) {
+		t.Errorf("read back skips %v, want %v", read.Skipped(), skip)
+	}
+
+	c, err := bisect.Replay(tc.Problem, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = bisect.Bisect(context.Background(), c, params, bisect.NewLocalOracle(tc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read.Update(c)
+	if !reflect.DeepEqual(read.Skipped(), skip) {
+		t.Errorf("carrying on lost the skips, got %v", read.Skips)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	tc := gen.TestCase(gen.Chain, "tiny-chain-0", 20, 1)
+	l := solveWithLog(t, tc, params)
+
+	wrongRepo := *l
+	wrongRepo.Repo = "tiny-chain-1"
+	wrongSolution := *l
+	wrongSolution.Solution = tc.Problem.Instance.Good
+	unfinished := *l
+	unfinished.Answers = nil
+	unknown := *l
+	unknown.Answers = append([]dag.Answer{{Commit: "nope"}}, l.Answers...)
+
+	tests := []struct {
+		name string
+		log  *bisect.BisectLog
+		want string
+	}{
+		{"wrong repo", &wrongRepo, "the log is for tiny-chain-1"},
+		{"wrong solution", &wrongSolution, "but the replay found"},
+		{"unfinished", &unfinished, "there are still"},
+		{"unknown commit", &unknown, "answer 1 (bad nope)"},
+	}
+	for _, tt := range tests {
+		_, err := bisect.Replay(tc.Problem, tt.log)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if err := l.Undo(len(l.Answers) + 1); err == nil {
+		t.Error("no error undoing more answers than there are")
+	}
+}
+
+func TestReadBisectLogErrors(t *testing.T) {
+	tests := []struct {
+		log  string
+		want string
+	}{
+		{"", "no start"},
+		{"repo x\ngood a\n", "line 2: answer before the start"},
+		{"start b a\nfrob c\n", "line 2: can't understand 'frob c'"},
+		{"skip c\nstart b a\n", "line 1: answer before the start"},
+		{"start b a\ngood\n", "line 2"},
+		{"start b\n", "line 1"},
+		{"start b a\nstart d c\n", "line 2"},
+	}
+	for _, tt := range tests {
+		_, err := bisect.ReadBisectLog(strings.NewReader(tt.log))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got error %v, want %q", tt.log, err, tt.want)
+		}
+	}
+}
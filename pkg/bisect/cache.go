@@ -0,0 +1,94 @@
+package bisect
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+)
+
+// RepoCache keeps the DAG of every repo we have been sent on disk, so the next time the same repo
+// turns up it is loaded from the compact binary format instead of being parsed and built again.
+// Repos are matched by the hash of the whole Repo message, or just by name if ByName is set,
+// which skips hashing but trusts the server never to reuse a name for a different repo.
+type RepoCache struct {
+	Dir    string
+	ByName bool
+}
+
+// NewRepoCache creates the cache directory if it doesn't exist yet
+func NewRepoCache(dir string, byName bool) (*RepoCache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &RepoCache{Dir: dir, ByName: byName}, nil
+}
+
+// ContentKey is the cache key for a Repo message, the hash of all of it, the same as decodeHashed works out
+func ContentKey(message []byte) string {
+	sum := sha256.Sum256(message)
+	return hex.EncodeToString(sum[:])
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+func (c *RepoCache) path(key string) string {
+	return filepath.Join(c.Dir, unsafeName.ReplaceAllString(key, "_")+".dag")
+}
+
+// Lookup returns the cached DAG for the key, or nil if there isn't one (or it can't be read)
+func (c *RepoCache) Lookup(key string) *dag.DAG {
+	d, err := dag.Load(c.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Ignoring the DAG cache for %v: %v", key, err)
+		}
+		return nil
+	}
+	log.Printf("Loaded %v from the DAG cache 📦", key)
+	return d
+}
+
+// Store saves the DAG under the key, which only gets logged if it goes wrong as the cache is just an optimisation
+func (c *RepoCache) Store(key string, d *dag.DAG) {
+	err := d.Save(c.path(key))
+	if err != nil {
+		log.Printf("Couldn't save %v to the DAG cache: %v", key, err)
+	}
+}
+
+// decodeHashed hashes the message while spooling it to a temporary file, so it is never all in memory,
+// and then decodes it from the file, skipping the dag if one is already cached under the hash.
+// The key is returned so a DAG that wasn't cached can be stored under it.
+func (c *RepoCache) decodeHashed(r io.Reader) (serverMessage, string, error) {
+	spool, err := ioutil.TempFile("", "gitbisect-message-*.json")
+	if err != nil {
+		return serverMessage{}, "", err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(spool, io.TeeReader(r, h))
+	if err != nil {
+		return serverMessage{}, "", err
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+
+	_, err = spool.Seek(0, io.SeekStart)
+	if err != nil {
+		return serverMessage{}, "", err
+	}
+	m, err := decodeServerMessage(bufio.NewReader(spool), func(string) *dag.DAG {
+		return c.Lookup(key)
+	})
+	return m, key, err
+}
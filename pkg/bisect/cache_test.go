@@ -0,0 +1,55 @@
+package bisect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeHashed(t *testing.T) {
+	cache, err := NewRepoCache(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := `{"Repo": {"name": "r", "instance_count": 2, "dag": [["a", []], ["b", ["a"]], ["c", ["b"]]]}}`
+
+	m, key, err := cache.decodeHashed(strings.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != ContentKey([]byte(message)) {
+		t.Errorf("key is %v, want %v", key, ContentKey([]byte(message)))
+	}
+	if m.cached || m.dag.GetOrder() != 3 {
+		t.Fatalf("first read got %v commits, cached %v", m.dag.GetOrder(), m.cached)
+	}
+	cache.Store(key, m.dag)
+
+	m, _, err = cache.decodeHashed(strings.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.cached || m.dag.GetOrder() != 3 || m.repo.Name != "r" || m.repo.InstanceCount != 2 {
+		t.Fatalf("second read got %+v with %v commits, cached %v", m.repo, m.dag.GetOrder(), m.cached)
+	}
+
+	// A cache hit stops at the dag, so even a dag that would never parse isn't looked at
+	broken := `{"Repo": {"name": "r", "instance_count": 2, "dag": [["a", [1, 2`
+	cache.Store(ContentKey([]byte(broken)), m.dag)
+	m, _, err = cache.decodeHashed(strings.NewReader(broken))
+	if err != nil {
+		t.Fatalf("the dag of a cached message was decoded: %v", err)
+	}
+	if !m.cached {
+		t.Error("the broken message wasn't found in the cache")
+	}
+}
+
+func TestSkipValue(t *testing.T) {
+	m, err := decodeServerMessage(strings.NewReader(`{"Other": {"a": [1, [2, {"b": null}]], "c": "d"}, "Score": {"r": {"Correct": 3}}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.score == nil || m.score.Score["r"].Questions != 3 {
+		t.Errorf("got %+v after skipping", m.score)
+	}
+}
@@ -2,34 +2,75 @@ package bisect
 
 import (
 	"encoding/json"
+	"fmt"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
 )
 
-// DAGEntry is the actual DAG part
+// DAGEntry is the actual DAG part, a commit and its parents
+// On the wire it is ["commit", ["parent", ...]], see UnmarshalJSON and MarshalJSON.
 type DAGEntry struct {
-	commit  string
-	parents []string
+	Commit  string
+	Parents []string
 }
 
-func (d *DAGEntry) UnmarshalJSON(data []byte) error {
+// NewDAGEntry creates an entry for the commit and its parents
+func NewDAGEntry(commit string, parents ...string) DAGEntry {
+	if parents == nil {
+		parents = []string{}
+	}
+	return DAGEntry{Commit: commit, Parents: parents}
+}
 
-	var v []interface{}
+// UnmarshalJSON reads an entry in the form ["commit", ["parent", ...]]
+// Anything else is an error rather than a panic, as it comes straight off the websocket.
+func (d *DAGEntry) UnmarshalJSON(data []byte) error {
+	var v []json.RawMessage
 	if err := json.Unmarshal(data, &v); err != nil {
-		return err
+		return fmt.Errorf("dag entry should be an array of [commit, parents], got %.100s", data)
+	}
+	if len(v) != 2 {
+		return fmt.Errorf("dag entry should have 2 elements, got %v in %.100s", len(v), data)
 	}
 
-	d.commit, _ = v[0].(string)
+	var commit string
+	if err := json.Unmarshal(v[0], &commit); err != nil {
+		return fmt.Errorf("dag entry commit should be a string, got %.100s", v[0])
+	}
+	if commit == "" {
+		return fmt.Errorf("dag entry commit is empty")
+	}
 
-	for _, parent := range v[1].([]interface{}) {
-		d.parents = append(d.parents, parent.(string))
+	var parents []string
+	if err := json.Unmarshal(v[1], &parents); err != nil || parents == nil {
+		return fmt.Errorf("parents of %s should be an array of strings, got %.100s", commit, v[1])
+	}
+	for _, parent := range parents {
+		if parent == "" {
+			return fmt.Errorf("parents of %s include an empty commit", commit)
+		}
 	}
 
+	d.Commit = commit
+	d.Parents = parents
 	return nil
 }
 
+// MarshalJSON writes the entry back out as ["commit", ["parent", ...]]
+func (d DAGEntry) MarshalJSON() ([]byte, error) {
+	parents := d.Parents
+	if parents == nil {
+		parents = []string{}
+	}
+	return json.Marshal([]interface{}{d.Commit, parents})
+}
+
 // ProblemInstance is just a container for the problem
+// DAG is the unpruned DAG when the repo was streamed straight into one (see DecodeRepoDAG), in which case Repo has no Dag.
 type ProblemInstance struct {
 	Repo     Repo
 	Instance Instance
+	DAG      *dag.DAG `json:"-"`
 }
 
 // RepoContainer is just a way to get around Radu's json formatting
@@ -66,12 +107,24 @@ type Answer struct {
 	Answer string `json:"Answer"`
 }
 
+// Questions asks about several commits at once, which only the local server understands
+type Questions struct {
+	Questions []string `json:"Questions"`
+}
+
+// Answers are the answers to Questions, in the same order
+type Answers struct {
+	Answers []string `json:"Answers"`
+}
+
 // Solution is the solution json interface
 type Solution struct {
 	Solution string `json:"Solution"`
 }
 
-// Score is the score json interface (should change for the websockets)
+// Score is the score json interface, with the result of every problem by the repo's name, for all of its instances
+// Bounds is never sent by the server, we fill it in with the optimal number of questions for each problem
 type Score struct {
-	Score map[string]interface{} `json:"Score"`
+	Score  map[string]ProblemResult `json:"Score"`
+	Bounds map[string]dag.Bound     `json:"-"`
 }
@@ -0,0 +1,132 @@
+package bisect_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/gen"
+)
+
+func TestRepoJSONRoundTrip(t *testing.T) {
+	for _, family := range gen.Names() {
+		repo := gen.Repo(gen.Families[family], "tiny-"+family, 20, 1)
+
+		data, err := json.Marshal(bisect.RepoContainer{Repo: repo})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded bisect.RepoContainer
+		err = json.Unmarshal(data, &decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(decoded.Repo, repo) {
+			t.Errorf("%v: %+v became %+v", family, repo, decoded.Repo)
+		}
+	}
+}
+
+func TestRepoDAGRoundTrip(t *testing.T) {
+	for _, family := range gen.Names() {
+		repo := gen.Repo(gen.Families[family], "small-"+family, 200, 1)
+		d := bisect.DAGMaker(&repo)
+
+		again := bisect.NewRepo(repo.Name, d)
+		if len(again.Dag) != len(repo.Dag) {
+			t.Errorf("%v: got %v entries, want %v", family, len(again.Dag), len(repo.Dag))
+		}
+
+		d2 := bisect.DAGMaker(&again)
+		if d2.GetOrder() != d.GetOrder() || d2.GetSize() != d.GetSize() {
+			t.Errorf("%v: got %v commits and %v edges, want %v and %v", family, d2.GetOrder(), d2.GetSize(), d.GetOrder(), d.GetSize())
+		}
+		if thrice := bisect.NewRepo(repo.Name, d2); !reflect.DeepEqual(thrice, again) {
+			t.Errorf("%v: NewRepo isn't the same for the same DAG", family)
+		}
+	}
+}
+
+// A commit on its own, with no parents or children, survives the round trip too
+func TestRepoDAGRoundTripIsolated(t *testing.T) {
+	repo := bisect.Repo{Name: "isolated", Dag: []bisect.DAGEntry{
+		bisect.NewDAGEntry("a"),
+		bisect.NewDAGEntry("b", "a"),
+		bisect.NewDAGEntry("lonely"),
+	}}
+
+	d := bisect.DAGMaker(&repo)
+	if d.GetOrder() != 3 || d.GetSize() != 1 {
+		t.Fatalf("got %v commits and %v edges, want 3 and 1", d.GetOrder(), d.GetSize())
+	}
+	if !d.GetVertices()["lonely"] {
+		t.Fatalf("lost the commit without parents or children")
+	}
+
+	again := bisect.NewRepo(repo.Name, d)
+	again.InstanceCount = repo.InstanceCount
+	if !reflect.DeepEqual(again, repo) {
+		t.Errorf("got %+v, want %+v", again, repo)
+	}
+	if d2 := bisect.DAGMaker(&again); d2.GetOrder() != 3 || d2.GetSize() != 1 {
+		t.Errorf("the second round trip got %v commits and %v edges", d2.GetOrder(), d2.GetSize())
+	}
+
+	message, err := json.Marshal(bisect.RepoContainer{Repo: repo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, streamed, err := bisect.DecodeRepoDAG(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !streamed.GetVertices()["lonely"] {
+		t.Errorf("the streamed DAG lost the commit without parents or children")
+	}
+}
+
+func TestDAGEntryWireForm(t *testing.T) {
+	data, err := json.Marshal([]bisect.DAGEntry{
+		bisect.NewDAGEntry("b", "a"),
+		bisect.NewDAGEntry("a"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[["b",["a"]],["a",[]]]`; string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+}
+
+// The local server sends repos in exactly the form the real one does, as in the README
+func TestRepoContainerWireForm(t *testing.T) {
+	repo := bisect.RepoContainer{Repo: bisect.Repo{
+		Name:          "pb0",
+		InstanceCount: 10,
+		Dag: []bisect.DAGEntry{
+			{Commit: "a"},
+			bisect.NewDAGEntry("b", "a"),
+			bisect.NewDAGEntry("c", "b"),
+		},
+	}}
+
+	data, err := json.Marshal(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Repo":{"name":"pb0","instance_count":10,"dag":[["a",[]],["b",["a"]],["c",["b"]]]}}`; string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+
+	var read bisect.RepoContainer
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	repo.Repo.Dag[0] = bisect.NewDAGEntry("a")
+	if !reflect.DeepEqual(read, repo) {
+		t.Errorf("read back %+v, want %+v", read, repo)
+	}
+}
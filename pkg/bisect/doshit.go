@@ -1,16 +1,14 @@
 package bisect
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"strconv"
+	"sort"
 	"time"
 
 	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
 )
 
-// DAGMaker takes the problem struct and returns the Dag
+// DAGMaker takes the problem struct and returns the Dag, see NewRepo for the other way round
 func DAGMaker(p *Repo) *dag.DAG {
 	// initialize a new graph
 	d := dag.NewDAG()
@@ -19,11 +17,12 @@ func DAGMaker(p *Repo) *dag.DAG {
 	var currentParentVertex string
 	var err error
 
-	// Add the vertex and edge's
+	// Add the vertex and edge's, the vertex on its own so a commit without parents or children is still there
 	for _, current := range p.Dag {
-		currentVertex = current.commit
+		currentVertex = current.Commit
+		d.AddVertex(currentVertex)
 
-		for _, parent := range current.parents {
+		for _, parent := range current.Parents {
 			currentParentVertex = parent
 
 			err = d.AddEdge(currentParentVertex, currentVertex)
@@ -36,80 +35,40 @@ func DAGMaker(p *Repo) *dag.DAG {
 	return d
 }
 
-// SaveResults saves the scores to a file
-func SaveResults(s *Score, start time.Time) error {
-	end := time.Now()
-
-	f, err := os.Create("results.txt")
-	if err != nil {
-		return err
+// NewRepo turns a DAG back into a Repo, with an entry for every commit in name order
+// DAGMaker(&NewRepo(name, d)) gives back the same DAG, commits without any parents or children included.
+func NewRepo(name string, d *dag.DAG) Repo {
+	var commits []string
+	for commit := range d.GetVertices() {
+		commits = append(commits, commit)
 	}
-
-	defer f.Close()
-
-	totalscore := 0
-	correctsolutions := 0
-	wrongsolutions := 0
-	gaveup := 0
-
-	for key, val := range s.Score {
-		corr, ok := val.(map[string]interface{})
-		if !ok {
-			wrong, ok := val.(string)
-			if !ok {
-				log.Printf("Failed failure analysis with %v, %v", key, val)
-			}
-			if wrong == "GaveUp" {
-				// If you give up, it counts as a score of 30 I believe?
-				gaveup++
-				_, err := f.WriteString(fmt.Sprintf("😤 %v", key))
-				if err != nil {
-					return err
-				}
-			} else if wrong == "Wrong" {
-				wrongsolutions++
-				_, err := f.WriteString(fmt.Sprintf("❌ %v", key))
-				if err != nil {
-					return err
-				}
-			}
-		} else {
-			finalint, ok := corr["Correct"].(float64)
-			if !ok {
-				finalstring, ok := corr["Correct"].(string)
-				if !ok {
-					log.Printf("Failed score analysis with %v, %v", key, val)
-				} else {
-					intversion, err := strconv.ParseInt(finalstring, 10, 32)
-					if err != nil {
-						return err
-					}
-					totalscore += int(intversion)
-					correctsolutions++
-					_, err = f.WriteString(fmt.Sprintf("✅ %v : %v\n", key, intversion))
-					if err != nil {
-						return err
-					}
-				}
-			} else {
-				totalscore += int(finalint)
-				correctsolutions++
-				_, err = f.WriteString(fmt.Sprintf("✅ %v : %v\n", key, finalint))
-				if err != nil {
-					return err
-				}
-			}
-
+	sort.Strings(commits)
+
+	entries := make([]DAGEntry, 0, len(commits))
+	for _, commit := range commits {
+		// Every commit came from GetVertices, so it can't be unknown
+		parents, _ := d.GetParents(commit)
+		entry := DAGEntry{Commit: commit, Parents: []string{}}
+		for parent := range parents {
+			entry.Parents = append(entry.Parents, parent)
 		}
+		sort.Strings(entry.Parents)
+		entries = append(entries, entry)
 	}
 
-	f.WriteString(fmt.Sprintf("Total problems: %v\n", len(s.Score)))
-	f.WriteString(fmt.Sprintf("Correct solutions: %v, Incorrect solutions: %v, GaveUp: %v\n", correctsolutions, wrongsolutions, gaveup))
-	f.WriteString(fmt.Sprintf("Total questions asked: %v\n", totalscore))
-	f.WriteString(fmt.Sprintf("Average questions per correct problem: %v\n", totalscore/correctsolutions))
-	f.WriteString(fmt.Sprintf("Started at: %v\n", start.String()))
-	f.WriteString(fmt.Sprintf("Completed at: %v\n", end.String()))
-	f.WriteString(fmt.Sprintf("Time taken: %v\n", time.Since(start).String()))
+	return Repo{
+		Name:          name,
+		InstanceCount: 1,
+		Dag:           entries,
+	}
+}
+
+// SaveResults saves the scores to results.txt
+func SaveResults(s *Score, start time.Time) error {
+	return SaveReport(s, start, "results.txt", report.Text)
+}
 
-	return f.Sync()
+// SaveReport saves the scores to the path in one of the report formats, guessing it from the extension if it is empty
+func SaveReport(s *Score, start time.Time, path, format string) error {
+	return report.New(s.Problems(), start, time.Now()).Save(path, format)
 }
@@ -0,0 +1,110 @@
+package bisect
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Run any of these for longer with go test ./pkg/bisect -run '^$' -fuzz FuzzDAGEntry
+
+func FuzzDAGEntry(f *testing.F) {
+	f.Add([]byte(`["a", []]`))
+	f.Add([]byte(`["a", ["b", "c"]]`))
+	f.Add([]byte(`["a"]`))
+	f.Add([]byte(`[1, ["b"]]`))
+	f.Add([]byte(`["a", "b"]`))
+	f.Add([]byte(`["a", [1]]`))
+	f.Add([]byte(`["a", null]`))
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`null`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var entry DAGEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return
+		}
+		if entry.Commit == "" || entry.Parents == nil {
+			t.Fatalf("decoded %s without an error into %#v", data, entry)
+		}
+
+		// Anything we can read, we can write back out and read again
+		again, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded DAGEntry
+		if err := json.Unmarshal(again, &decoded); err != nil {
+			t.Fatalf("couldn't read back %s: %v", again, err)
+		}
+		if !reflect.DeepEqual(entry, decoded) {
+			t.Fatalf("%#v became %#v", entry, decoded)
+		}
+	})
+}
+
+func FuzzRepoContainer(f *testing.F) {
+	f.Add([]byte(`{"Repo": {"name": "tiny-chain-0", "instance_count": 1, "dag": [["b", ["a"]], ["a", []]]}}`))
+	f.Add([]byte(`{"Repo": {"name": "x", "dag": [["b"]]}}`))
+	f.Add([]byte(`{"Repo": {"dag": {}}}`))
+	f.Add([]byte(`{"Repo": null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var repo RepoContainer
+		if err := json.Unmarshal(data, &repo); err != nil {
+			return
+		}
+
+		// Whatever we get has to be safe to build a DAG from
+		DAGMaker(&repo.Repo)
+	})
+}
+
+func FuzzInstanceContainer(f *testing.F) {
+	f.Add([]byte(`{"Instance": {"good": "a", "bad": "b"}}`))
+	f.Add([]byte(`{"Instance": {"good": 1}}`))
+	f.Add([]byte(`{"Instance": []}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var inst InstanceContainer
+		json.Unmarshal(data, &inst)
+	})
+}
+
+func FuzzScore(f *testing.F) {
+	f.Add([]byte(`{"Score": {"tiny-chain-0": {"Correct": 7}, "tiny-chain-1": "Wrong", "tiny-chain-2": "GaveUp"}}`))
+	f.Add([]byte(`{"Score": {"a": {"Correct": "7"}}}`))
+	f.Add([]byte(`{"Score": {"a": {"Correct": -1}}}`))
+	f.Add([]byte(`{"Score": {"a": {"Correct": 1.5}}}`))
+	f.Add([]byte(`{"Score": {"a": {"Correct": 1e300}}}`))
+	f.Add([]byte(`{"Score": {"a": {"Correct": 7, "Wrong": 1}}}`))
+	f.Add([]byte(`{"Score": {"a": "Right"}}`))
+	f.Add([]byte(`{"Score": {"a": null}}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var s Score
+		if err := json.Unmarshal(data, &s); err != nil {
+			return
+		}
+
+		for name, result := range s.Score {
+			if result.Questions < 0 {
+				t.Fatalf("%v has %v questions", name, result.Questions)
+			}
+		}
+		s.Problems()
+
+		// Anything we can read, we can write back out and read again
+		again, err := json.Marshal(s)
+		if err != nil {
+			t.Fatalf("couldn't write back %v: %v", s.Score, err)
+		}
+		var decoded Score
+		if err := json.Unmarshal(again, &decoded); err != nil {
+			t.Fatalf("couldn't read back %s: %v", again, err)
+		}
+		if !reflect.DeepEqual(s.Score, decoded.Score) {
+			t.Fatalf("%v became %v", s.Score, decoded.Score)
+		}
+	})
+}
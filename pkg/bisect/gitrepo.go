@@ -0,0 +1,89 @@
+package bisect
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// git runs git in the repository, returning what it printed
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// ResolveGit turns a revision like HEAD~3, a branch or a tag into the full sha of its commit
+func ResolveGit(dir, rev string) (string, error) {
+	out, err := git(dir, "rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DescribeGit is the short sha and subject of the commit, like git log --oneline
+func DescribeGit(dir, commit string) (string, error) {
+	out, err := git(dir, "show", "-s", "--format=%h %s", commit)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// RepoFromGit reads the history of a local git repository as a Repo, every ancestor of the revision (and itself)
+// It is named after the repository's directory.
+func RepoFromGit(dir, rev string) (Repo, error) {
+	out, err := git(dir, "rev-list", "--parents", rev)
+	if err != nil {
+		return Repo{}, err
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Repo{}, err
+	}
+	repo := Repo{
+		Name:          filepath.Base(abs),
+		InstanceCount: 1,
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		repo.Dag = append(repo.Dag, NewDAGEntry(fields[0], fields[1:]...))
+	}
+	if len(repo.Dag) == 0 {
+		return Repo{}, fmt.Errorf("%s has no commits", dir)
+	}
+	return repo, scanner.Err()
+}
+
+// TouchingFromGit is every ancestor of the revision (and itself) that changes one of the paths, like git bisect -- <paths>
+func TouchingFromGit(dir, rev string, paths []string) (map[string]bool, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths to limit the bisection to")
+	}
+
+	out, err := git(dir, append([]string{"rev-list", "--full-history", rev, "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	touching := make(map[string]bool)
+	for _, commit := range strings.Fields(string(out)) {
+		touching[commit] = true
+	}
+	return touching, nil
+}
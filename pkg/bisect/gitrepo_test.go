@@ -0,0 +1,87 @@
+package bisect_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+)
+
+// gitRepo makes a repository with a few commits on master and a branch merged back in
+func gitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	dir, err := ioutil.TempDir("", "gitrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	commit := func(file string) {
+		err := ioutil.WriteFile(filepath.Join(dir, file), []byte(file), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		run("add", file)
+		run("commit", "-q", "-m", "add "+file)
+	}
+
+	run("init", "-q")
+	run("checkout", "-q", "-b", "master")
+	commit("a")
+	commit("b")
+	run("checkout", "-q", "-b", "side")
+	commit("c")
+	run("checkout", "-q", "master")
+	commit("d")
+	run("merge", "-q", "--no-edit", "side")
+	return dir
+}
+
+func TestRepoFromGit(t *testing.T) {
+	dir := gitRepo(t)
+
+	repo, err := bisect.RepoFromGit(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Name != filepath.Base(dir) || len(repo.Dag) != 5 {
+		t.Fatalf("got %v with %v commits, want %v with 5", repo.Name, len(repo.Dag), filepath.Base(dir))
+	}
+
+	head, err := bisect.ResolveGit(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := bisect.ResolveGit(dir, "HEAD~3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := bisect.DAGMaker(&repo)
+	if parents, err := d.GetParents(head); err != nil || len(parents) != 2 {
+		t.Errorf("the merge has parents %v (%v), want 2", parents, err)
+	}
+	if n, err := d.GetAncestorsLength(first); err != nil || n != 0 {
+		t.Errorf("HEAD~3 has %v ancestors (%v), want it to be the root", n, err)
+	}
+
+	description, err := bisect.DescribeGit(dir, first)
+	if err != nil || !strings.HasSuffix(description, "add a") {
+		t.Errorf("got %q (%v) describing the root", description, err)
+	}
+
+	if _, err := bisect.ResolveGit(dir, "nope"); err == nil {
+		t.Error("no error resolving a revision that doesn't exist")
+	}
+}
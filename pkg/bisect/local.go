@@ -0,0 +1,258 @@
+package bisect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+)
+
+// TestCase is a problem from the tests directory, along with the actual answer
+// The files are a two element array, the problem as the server would send it and then the answer:
+//
+//	[
+//		{"name": "react0", "good": "<sha>", "bad": "<sha>", "dag": [["<sha>", ["<parent sha>", ...]], ...]},
+//		{"bug": "<sha>", "all_bad": ["<sha>", ...]}
+//	]
+//
+// all_bad is every commit with the bug, which is the bug and all of its descendants.
+type TestCase struct {
+	Problem ProblemInstance
+	Bug     string
+	AllBad  []string
+}
+
+type testCaseProblem struct {
+	Name string     `json:"name"`
+	Good string     `json:"good"`
+	Bad  string     `json:"bad"`
+	Dag  []DAGEntry `json:"dag"`
+}
+
+type testCaseAnswer struct {
+	Bug    string   `json:"bug"`
+	AllBad []string `json:"all_bad"`
+}
+
+// UnmarshalJSON reads the two element array used by the test files
+func (t *TestCase) UnmarshalJSON(data []byte) error {
+	var parts []json.RawMessage
+	var prob testCaseProblem
+	var ans testCaseAnswer
+
+	err := json.Unmarshal(data, &parts)
+	if err != nil {
+		return err
+	}
+	if len(parts) != 2 {
+		return fmt.Errorf("test case should have 2 parts, got %v", len(parts))
+	}
+
+	err = json.Unmarshal(parts[0], &prob)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(parts[1], &ans)
+	if err != nil {
+		return err
+	}
+
+	t.Problem = ProblemInstance{
+		Repo: Repo{
+			Name:          prob.Name,
+			InstanceCount: 1,
+			Dag:           prob.Dag,
+		},
+		Instance: Instance{
+			Good: prob.Good,
+			Bad:  prob.Bad,
+		},
+	}
+	t.Bug = ans.Bug
+	t.AllBad = ans.AllBad
+
+	return nil
+}
+
+// Validate checks the answer makes sense for the problem, so a broken test file isn't mistaken for a broken solver
+// The good, bad and bug commits must all be in the DAG, and the bug and bad commits must be bad but the good one not.
+func (t *TestCase) Validate() error {
+	commits := make(map[string]bool)
+	for _, entry := range t.Problem.Repo.Dag {
+		commits[entry.Commit] = true
+	}
+	bad := make(map[string]bool)
+	for _, commit := range t.AllBad {
+		bad[commit] = true
+	}
+
+	switch {
+	case t.Problem.Repo.Name == "":
+		return fmt.Errorf("test case has no name")
+	case !commits[t.Problem.Instance.Good]:
+		return fmt.Errorf("good commit '%s' is not in the DAG", t.Problem.Instance.Good)
+	case !commits[t.Problem.Instance.Bad]:
+		return fmt.Errorf("bad commit '%s' is not in the DAG", t.Problem.Instance.Bad)
+	case !commits[t.Bug]:
+		return fmt.Errorf("bug '%s' is not in the DAG", t.Bug)
+	case bad[t.Problem.Instance.Good]:
+		return fmt.Errorf("good commit '%s' is in all_bad", t.Problem.Instance.Good)
+	case !bad[t.Problem.Instance.Bad]:
+		return fmt.Errorf("bad commit '%s' is not in all_bad", t.Problem.Instance.Bad)
+	case !bad[t.Bug]:
+		return fmt.Errorf("bug '%s' is not in all_bad", t.Bug)
+	}
+
+	return nil
+}
+
+// MarshalJSON writes the test case back out in the same two element array as the test files
+func (t TestCase) MarshalJSON() ([]byte, error) {
+	allBad := t.AllBad
+	if allBad == nil {
+		allBad = []string{}
+	}
+	return json.Marshal([]interface{}{
+		testCaseProblem{
+			Name: t.Problem.Repo.Name,
+			Good: t.Problem.Instance.Good,
+			Bad:  t.Problem.Instance.Bad,
+			Dag:  t.Problem.Repo.Dag,
+		},
+		testCaseAnswer{
+			Bug:    t.Bug,
+			AllBad: allBad,
+		},
+	})
+}
+
+// SaveTestCase writes a single test file
+func SaveTestCase(path string, t *TestCase) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadTestCase reads a single test file
+func LoadTestCase(path string) (*TestCase, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var t TestCase
+	err = json.Unmarshal(data, &t)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	err = t.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &t, nil
+}
+
+// LoadProblem reads a repo to bisect from a file, which can be a test problem (without looking at its answer),
+// a Repo message, a bare Repo or a DAG saved with dag.Save. Only a test problem has an Instance, and a saved DAG
+// is named after its file.
+func LoadProblem(path string) (ProblemInstance, error) {
+	var problem ProblemInstance
+
+	if strings.EqualFold(filepath.Ext(path), ".dag") {
+		d, err := dag.Load(path)
+		if err != nil {
+			return problem, err
+		}
+		problem.DAG = d
+		problem.Repo.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		return problem, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return problem, err
+	}
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte("[")) {
+		var t TestCase
+		err = json.Unmarshal(data, &t)
+		if err != nil {
+			return problem, fmt.Errorf("%s: %v", path, err)
+		}
+		return t.Problem, nil
+	}
+
+	var container RepoContainer
+	err = json.Unmarshal(data, &container)
+	if err == nil && container.Repo.Dag == nil {
+		err = json.Unmarshal(data, &container.Repo)
+	}
+	if err != nil {
+		return problem, fmt.Errorf("%s: %v", path, err)
+	}
+	problem.Repo = container.Repo
+	return problem, nil
+}
+
+// LoadTestCases reads every test file matching the glob pattern, in name order
+func LoadTestCases(pattern string) ([]*TestCase, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var cases []*TestCase
+	for _, path := range paths {
+		t, err := LoadTestCase(path)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, t)
+	}
+
+	return cases, nil
+}
+
+// LocalOracle plays the role of the server, answering questions from a TestCase
+type LocalOracle struct {
+	bad map[string]bool
+}
+
+// NewLocalOracle creates an oracle that knows which commits are bad
+func NewLocalOracle(t *TestCase) *LocalOracle {
+	bad := make(map[string]bool)
+	for _, commit := range t.AllBad {
+		bad[commit] = true
+	}
+	return &LocalOracle{bad}
+}
+
+// Ask answers "Bad" if the commit contains the bug, "Good" otherwise
+func (o *LocalOracle) Ask(ctx context.Context, q Question) (Answer, error) {
+	if o.bad[q.Question] {
+		return Answer{Answer: "Bad"}, nil
+	}
+	return Answer{Answer: "Good"}, nil
+}
+
+// SolveTestCase solves the test case locally, returning the solution and the number of questions asked
+func SolveTestCase(ctx context.Context, t *TestCase, pc dag.ParamConfig) (Solution, int, error) {
+	c, err := PrepareCandidates(t.Problem)
+	if err != nil {
+		return Solution{}, 0, err
+	}
+
+	return Bisect(ctx, c, pc, NewLocalOracle(t))
+}
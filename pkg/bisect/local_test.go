@@ -0,0 +1,137 @@
+package bisect_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/gen"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+// The real problems take a few seconds each, so by default only the first of each family is solved, to keep
+// go test well inside its timeout as more are added. go test ./pkg/bisect -per-family 0 solves all of them.
+var fixtures = flag.String("fixtures", "../../tests/*.json", "glob of the test problems to solve")
+var perFamily = flag.Int("per-family", 1, "how many test problems to solve from each family (0 = all of them)")
+
+var params = dag.ParamConfig{Limit: 5000, Divisions: 50, Merges: 100, Strategy: dag.StrategyAuto}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	// The solver logs every question
+	if !testing.Verbose() {
+		log.SetOutput(ioutil.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// solve checks the solver finds the bug, and that it doesn't ask more questions than there are commits
+func solve(t *testing.T, c *bisect.TestCase, pc dag.ParamConfig) {
+	solution, questions, err := bisect.SolveTestCase(context.Background(), c, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solution.Solution != c.Bug {
+		t.Fatalf("found %v, but the bug is %v", solution.Solution, c.Bug)
+	}
+	if questions > len(c.Problem.Repo.Dag) {
+		t.Fatalf("asked %v questions about %v commits", questions, len(c.Problem.Repo.Dag))
+	}
+	t.Logf("%v solved with %v questions", c.Problem.Repo.Name, questions)
+}
+
+func TestFixtures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the real problems take a while")
+	}
+
+	cases, err := bisect.LoadTestCases(*fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Skipf("no test problems match %v", *fixtures)
+	}
+
+	solved := make(map[string]int)
+	for _, c := range cases {
+		c := c
+		family := report.Family(c.Problem.Repo.Name)
+		if *perFamily > 0 && solved[family] >= *perFamily {
+			continue
+		}
+		solved[family]++
+
+		t.Run(c.Problem.Repo.Name, func(t *testing.T) {
+			t.Parallel()
+			solve(t, c, params)
+		})
+	}
+}
+
+func TestGenerated(t *testing.T) {
+	for _, family := range gen.Names() {
+		for _, size := range []string{"tiny", "small"} {
+			for seed := int64(0); seed < 5; seed++ {
+				name := fmt.Sprintf("%v-%v-%v", size, family, seed)
+				commits, _ := gen.ParseSize(size)
+				c := gen.TestCase(gen.Families[family], name, commits, seed)
+
+				t.Run(name, func(t *testing.T) {
+					err := c.Validate()
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, strategy := range dag.Strategies {
+						pc := params
+						pc.Strategy = strategy
+						solve(t, c, pc)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestTestCaseRoundTrip(t *testing.T) {
+	c := gen.TestCase(gen.Octopus, "tiny-octopus-0", 20, 1)
+
+	path := t.TempDir() + "/test_tiny-octopus-0.json"
+	err := bisect.SaveTestCase(path, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := bisect.LoadTestCase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Problem.Repo.Name != c.Problem.Repo.Name || loaded.Problem.Instance != c.Problem.Instance || loaded.Bug != c.Bug {
+		t.Fatalf("loaded %+v, saved %+v", loaded.Problem.Instance, c.Problem.Instance)
+	}
+	if len(loaded.Problem.Repo.Dag) != len(c.Problem.Repo.Dag) || len(loaded.AllBad) != len(c.AllBad) {
+		t.Fatalf("loaded %v commits and %v bad, saved %v and %v",
+			len(loaded.Problem.Repo.Dag), len(loaded.AllBad), len(c.Problem.Repo.Dag), len(c.AllBad))
+	}
+}
+
+func TestLoadTestCaseRejectsBadAnswers(t *testing.T) {
+	c := gen.TestCase(gen.Chain, "tiny-chain-0", 20, 1)
+	c.AllBad = append(c.AllBad, c.Problem.Instance.Good)
+
+	path := t.TempDir() + "/test_tiny-chain-0.json"
+	err := bisect.SaveTestCase(path, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bisect.LoadTestCase(path)
+	if err == nil {
+		t.Fatal("expected an error when the good commit is bad")
+	}
+}
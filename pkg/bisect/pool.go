@@ -0,0 +1,135 @@
+package bisect
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+// Solve authenticates, and then solves problems until the server replies with the Score
+func (c *Connection) Solve(ctx context.Context, a Authentication, pc dag.ParamConfig) (Score, error) {
+	problem, err := c.GetProblemWebsocket(ctx, a)
+	if err != nil {
+		log.Print("You... Shall... not.... be authorised to connect to this server 😢")
+		return Score{}, err
+	}
+
+	log.Printf("Retrieved problem %v, parsing...", problem.Repo.Name)
+
+	cands, err := PrepareCandidates(problem)
+	if err != nil {
+		return Score{}, err
+	}
+	c.apply(cands)
+
+	return c.NextMoveWebsocket(ctx, cands, pc, problem)
+}
+
+// MergeScores combines the Score maps from several sessions into one
+// A repo scored by more than one session had its instances split between them, so they are added together.
+func MergeScores(scores ...Score) Score {
+	merged := Score{
+		Score:  make(map[string]ProblemResult),
+		Bounds: make(map[string]dag.Bound),
+	}
+	for _, s := range scores {
+		for name, bound := range s.Bounds {
+			merged.addBound(name, bound)
+		}
+		for name, result := range s.Score {
+			merged.addResult(name, result)
+		}
+	}
+	return merged
+}
+
+// runPool runs the session function on n goroutines, and merges all of their scores
+// Every session runs to completion, and the first error is returned along with whatever was scored
+func runPool(n int, session func(worker int) (Score, error)) (Score, error) {
+	scores := make([]Score, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			scores[w], errs[w] = session(w)
+		}(w)
+	}
+	wg.Wait()
+
+	merged := MergeScores(scores...)
+	for _, err := range errs {
+		if err != nil {
+			return merged, err
+		}
+	}
+	return merged, nil
+}
+
+// SolvePool opens n authenticated connections to the server and solves on all of them at once
+// This only helps if the server hands out different problems to each connection.
+func SolvePool(ctx context.Context, n int, u url.URL, t time.Duration, a Authentication, pc dag.ParamConfig, opts Options) (Score, error) {
+	return runPool(n, func(worker int) (Score, error) {
+		conn, err := ConnectWebsocket(ctx, u, t)
+		if err != nil {
+			return Score{}, err
+		}
+		defer conn.Close()
+		conn.Options = opts
+
+		log.Printf("Connection %v connected to websocket 🤖✅", worker)
+
+		return conn.Solve(ctx, a, pc)
+	})
+}
+
+// SolveLocalPool solves the test cases in process with n workers, scoring them like the server would
+// Cancelling the context stops every worker after its current question, returning what has been scored so far
+// The options' cache isn't used, as the problems are already in memory.
+func SolveLocalPool(ctx context.Context, n int, cases []*TestCase, pc dag.ParamConfig, opts Options) (Score, error) {
+	jobs := make(chan *TestCase, len(cases))
+	for _, t := range cases {
+		jobs <- t
+	}
+	close(jobs)
+
+	return runPool(n, func(worker int) (Score, error) {
+		s := Score{
+			Score:  make(map[string]ProblemResult),
+			Bounds: make(map[string]dag.Bound),
+		}
+		savedDAGs := make(map[string]bool)
+		for t := range jobs {
+			name := t.Problem.Repo.Name
+
+			c, err := PrepareCandidates(t.Problem)
+			if err != nil {
+				return s, err
+			}
+			opts.apply(c)
+			s.addBound(name, c.View().GetBound(dag.ExactLimit))
+
+			solution, questions, err := BisectBatch(ctx, c, pc, NewLocalOracle(t), opts.Batch)
+			saveBisectLog(opts.Logs, t.Problem, c, savedDAGs)
+			solution, err = guessIfUntestable(name, c, solution, err)
+			if err != nil {
+				return s, err
+			}
+
+			if solution.Solution == t.Bug {
+				s.addResult(name, ProblemResult{Outcome: report.Correct, Questions: questions})
+			} else {
+				s.addResult(name, ProblemResult{Outcome: report.Wrong})
+			}
+			log.Printf("Worker %v solved %v with %v questions", worker, name, questions)
+		}
+		return s, nil
+	})
+}
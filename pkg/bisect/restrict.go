@@ -0,0 +1,74 @@
+package bisect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// FirstParentChain is the bad commit and its first parent, and that one's first parent, and so on, like git bisect --first-parent
+// The first parent is the first one listed in the repo, which is the branch that was merged into.
+func FirstParentChain(repo *Repo, bad string) (map[string]bool, error) {
+	first := make(map[string]string, len(repo.Dag))
+	known := make(map[string]bool, len(repo.Dag))
+	for _, entry := range repo.Dag {
+		known[entry.Commit] = true
+		if len(entry.Parents) > 0 {
+			first[entry.Commit] = entry.Parents[0]
+		}
+	}
+	if !known[bad] {
+		return nil, fmt.Errorf("bad commit '%s' is not in %v", bad, repo.Name)
+	}
+
+	chain := make(map[string]bool)
+	for commit := bad; commit != "" && !chain[commit]; commit = first[commit] {
+		chain[commit] = true
+	}
+	return chain, nil
+}
+
+// Tags are the paths each commit changes, for limiting the bisection to some of them without a git repository
+type Tags map[string][]string
+
+// LoadTags reads the tags from a json file, {"<sha>": ["src/parser.go", "docs/"], ...}
+func LoadTags(path string) (Tags, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Tags
+	err = json.Unmarshal(data, &t)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+// Touching is every commit that changes one of the paths, which is either the path itself or anything under it
+func (t Tags) Touching(paths []string) map[string]bool {
+	touching := make(map[string]bool)
+	for commit, files := range t {
+		for _, file := range files {
+			if underAny(file, paths) {
+				touching[commit] = true
+				break
+			}
+		}
+	}
+	return touching
+}
+
+// underAny is whether the file is one of the paths, or in one of them
+func underAny(file string, paths []string) bool {
+	file = strings.Trim(file, "/")
+	for _, path := range paths {
+		path = strings.Trim(path, "/")
+		if path == "" || file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package bisect_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+)
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The bug is in c on the side branch, so the first-parent bisection finds the merge, and the path-limited one c itself
+func TestLimitedBisectOfGit(t *testing.T) {
+	dir := gitRepo(t)
+
+	resolve := func(rev string) string {
+		sha, err := bisect.ResolveGit(dir, rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sha
+	}
+	merge, a, b, c, d := resolve("HEAD"), resolve("HEAD~3"), resolve("HEAD~2"), resolve("side"), resolve("HEAD~1")
+
+	repo, err := bisect.RepoFromGit(dir, merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem := bisect.ProblemInstance{Repo: repo, Instance: bisect.Instance{Good: a, Bad: merge}}
+	oracle := bisect.NewLocalOracle(&bisect.TestCase{AllBad: []string{c, merge}})
+
+	chain, err := bisect.FirstParentChain(&repo, merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedKeys(chain), sortedKeys(map[string]bool{merge: true, d: true, b: true, a: true}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("first-parent chain is %v, want %v", got, want)
+	}
+
+	touching, err := bisect.TouchingFromGit(dir, merge, []string{"c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !touching[c] || touching[d] || touching[b] {
+		t.Fatalf("commits touching c are %v, want %v", sortedKeys(touching), c)
+	}
+
+	tests := []struct {
+		name string
+		only map[string]bool
+		want string
+	}{
+		{"first-parent", chain, merge},
+		{"paths", touching, c},
+	}
+	for _, tt := range tests {
+		cands, err := bisect.PrepareCandidates(problem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cands.SetOnly(tt.only)
+		solution, _, err := bisect.Bisect(context.Background(), cands, params, oracle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if solution.Solution != tt.want {
+			t.Errorf("%v: found %v, want %v", tt.name, solution.Solution, tt.want)
+		}
+
+		// The log keeps the limit, so it replays to the same place
+		l := bisect.NewBisectLog(problem, cands)
+		read, err := bisect.ReadBisectLog(strings.NewReader(l.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(read.Only, sortedKeys(tt.only)) {
+			t.Errorf("%v: the log is limited to %v, want %v", tt.name, read.Only, sortedKeys(tt.only))
+		}
+		replayed, err := bisect.Replay(problem, read)
+		if err != nil {
+			t.Fatalf("%v: %v\n%v", tt.name, err, l)
+		}
+		if replayed.MostRecentBad() != tt.want {
+			t.Errorf("%v: replay found %v, want %v", tt.name, replayed.MostRecentBad(), tt.want)
+		}
+	}
+}
+
+func TestFirstParentChainUnknown(t *testing.T) {
+	repo := bisect.Repo{Name: "r", Dag: []bisect.DAGEntry{bisect.NewDAGEntry("a")}}
+	if _, err := bisect.FirstParentChain(&repo, "b"); err == nil {
+		t.Error("no error for a bad commit that isn't in the repo")
+	}
+}
+
+func TestTagsTouching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.json")
+	err := ioutil.WriteFile(path, []byte(`{
+		"a": ["src/parser.go"],
+		"b": ["src/parser_test.go", "README.md"],
+		"c": ["docs/src/parser.go"],
+		"d": ["srcs/x.go"],
+		"e": []
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := bisect.LoadTags(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{[]string{"src"}, []string{"a", "b"}},
+		{[]string{"src/"}, []string{"a", "b"}},
+		{[]string{"src/parser.go"}, []string{"a"}},
+		{[]string{"README.md", "docs"}, []string{"b", "c"}},
+		{[]string{"nope"}, nil},
+	}
+	for _, tt := range tests {
+		if got := sortedKeys(tags.Touching(tt.paths)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.paths, got, tt.want)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"a": "src"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bisect.LoadTags(path); err == nil {
+		t.Error("no error for a commit without a list of paths")
+	}
+}
@@ -0,0 +1,152 @@
+package bisect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+// ProblemResult is how a single problem went, decoded from one of the forms the server uses:
+//
+//	{"Correct": 7} or {"Correct": "7"}
+//	"Wrong"
+//	"GaveUp"
+//
+// We also use {"Submitted": 7} ourselves, for problems we submitted but never got the Score for.
+type ProblemResult struct {
+	// Outcome is one of report.Correct, report.Wrong, report.GaveUp or report.Submitted
+	Outcome string
+	// Questions is the number of questions asked, only for Correct and Submitted
+	Questions int
+}
+
+// UnmarshalJSON decodes any of the server's result forms
+// A missing (null) result is an error, rather than a result with no outcome.
+func (p *ProblemResult) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return fmt.Errorf("result is missing (null)")
+	}
+
+	var outcome string
+	if err := json.Unmarshal(data, &outcome); err == nil {
+		if outcome != report.Wrong && outcome != report.GaveUp {
+			return fmt.Errorf("unknown result '%s'", outcome)
+		}
+		*p = ProblemResult{Outcome: outcome}
+		return nil
+	}
+
+	var counted map[string]json.RawMessage
+	if err := json.Unmarshal(data, &counted); err != nil {
+		return fmt.Errorf("result should be a string or an object, got %s", data)
+	}
+	if len(counted) != 1 {
+		return fmt.Errorf("result should have exactly one outcome, got %s", data)
+	}
+
+	for outcome, raw := range counted {
+		if outcome != report.Correct && outcome != report.Submitted {
+			return fmt.Errorf("unknown result '%s'", outcome)
+		}
+
+		questions, err := decodeQuestions(raw)
+		if err != nil {
+			return fmt.Errorf("bad question count for '%s': %v", outcome, err)
+		}
+		*p = ProblemResult{Outcome: outcome, Questions: questions}
+	}
+
+	return nil
+}
+
+// decodeQuestions accepts the question count as a number or a string
+func decodeQuestions(raw json.RawMessage) (int, error) {
+	if string(bytes.TrimSpace(raw)) == "null" {
+		return 0, fmt.Errorf("the count is missing (null)")
+	}
+
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		if number < 0 || number != float64(int(number)) {
+			return 0, fmt.Errorf("%v is not a count", number)
+		}
+		return int(number), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, fmt.Errorf("expected a number or a string, got %s", raw)
+	}
+	questions, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if questions < 0 {
+		return 0, fmt.Errorf("%v is not a count", questions)
+	}
+	return questions, nil
+}
+
+// MarshalJSON writes the result in the same form the server uses
+func (p ProblemResult) MarshalJSON() ([]byte, error) {
+	switch p.Outcome {
+	case report.Wrong, report.GaveUp:
+		return json.Marshal(p.Outcome)
+	case report.Correct, report.Submitted:
+		return json.Marshal(map[string]int{p.Outcome: p.Questions})
+	default:
+		return nil, fmt.Errorf("unknown outcome '%s'", p.Outcome)
+	}
+}
+
+// Problems turns the Score into the problems for a report
+func (s *Score) Problems() []report.Problem {
+	var problems []report.Problem
+	for name, result := range s.Score {
+		bound, bounded := s.Bounds[name]
+		problems = append(problems, report.Problem{
+			Name:      name,
+			Family:    report.Family(name),
+			Outcome:   result.Outcome,
+			Questions: result.Questions,
+			Optimal:   bound.Best(),
+			Bounded:   bounded,
+			Exact:     bound.Exact,
+		})
+	}
+	return problems
+}
+
+// addResult adds the result of one instance of a repo to the Score, which like the server's has one result per repo
+// The questions add up while every instance is solved, and otherwise the repo has the first instance that wasn't.
+func (s *Score) addResult(name string, result ProblemResult) {
+	total, ok := s.Score[name]
+	switch {
+	case !ok:
+		s.Score[name] = result
+	case total.Outcome == report.Wrong || total.Outcome == report.GaveUp:
+		// The repo has already failed
+	case result.Outcome == report.Wrong || result.Outcome == report.GaveUp:
+		s.Score[name] = result
+	default:
+		total.Questions += result.Questions
+		s.Score[name] = total
+	}
+}
+
+// addBound adds the bound of one instance of a repo to the repo's, so it can be compared with the questions for all of them
+func (s *Score) addBound(name string, bound dag.Bound) {
+	if total, ok := s.Bounds[name]; ok {
+		bound = dag.Bound{
+			Candidates: total.Candidates + bound.Candidates,
+			Lower:      total.Lower + bound.Lower,
+			Optimal:    total.Optimal + bound.Optimal,
+			Exact:      total.Exact && bound.Exact,
+		}
+	}
+	s.Bounds[name] = bound
+}
@@ -0,0 +1,87 @@
+package bisect_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+func TestProblemResultJSON(t *testing.T) {
+	tests := []struct {
+		result bisect.ProblemResult
+		json   string
+	}{
+		{bisect.ProblemResult{Outcome: report.Correct, Questions: 7}, `{"Correct":7}`},
+		{bisect.ProblemResult{Outcome: report.Correct}, `{"Correct":0}`},
+		{bisect.ProblemResult{Outcome: report.Submitted, Questions: 3}, `{"Submitted":3}`},
+		{bisect.ProblemResult{Outcome: report.Wrong}, `"Wrong"`},
+		{bisect.ProblemResult{Outcome: report.GaveUp}, `"GaveUp"`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.result)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.result, err)
+		} else if string(data) != tt.json {
+			t.Errorf("%+v: wrote %s, want %s", tt.result, data, tt.json)
+		}
+
+		var decoded bisect.ProblemResult
+		if err := json.Unmarshal([]byte(tt.json), &decoded); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+		} else if decoded != tt.result {
+			t.Errorf("%s: read %+v, want %+v", tt.json, decoded, tt.result)
+		}
+	}
+
+	// The server sometimes sends the count as a string
+	var decoded bisect.ProblemResult
+	if err := json.Unmarshal([]byte(`{"Correct": "12"}`), &decoded); err != nil || decoded != (bisect.ProblemResult{Outcome: report.Correct, Questions: 12}) {
+		t.Errorf("a count in a string read %+v (%v)", decoded, err)
+	}
+
+	if _, err := json.Marshal(bisect.ProblemResult{Outcome: "Right"}); err == nil || !strings.Contains(err.Error(), "unknown outcome 'Right'") {
+		t.Errorf("writing an unknown outcome got error %v", err)
+	}
+	if _, err := json.Marshal(bisect.ProblemResult{}); err == nil {
+		t.Error("wrote a result without an outcome")
+	}
+}
+
+func TestProblemResultJSONErrors(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`null`, "result is missing"},
+		{`"Right"`, "unknown result 'Right'"},
+		{`""`, "unknown result ''"},
+		{`"Correct"`, "unknown result 'Correct'"},
+		{`{"Right": 1}`, "unknown result 'Right'"},
+		{`{"Wrong": 1}`, "unknown result 'Wrong'"},
+		{`{}`, "exactly one outcome"},
+		{`{"Correct": 1, "Submitted": 2}`, "exactly one outcome"},
+		{`{"Correct": -1}`, "is not a count"},
+		{`{"Correct": 1.5}`, "is not a count"},
+		{`{"Correct": "-1"}`, "is not a count"},
+		{`{"Correct": "seven"}`, "bad question count"},
+		{`{"Correct": null}`, "bad question count"},
+		{`7`, "should be a string or an object"},
+		{`["Wrong"]`, "should be a string or an object"},
+	}
+	for _, tt := range tests {
+		var decoded bisect.ProblemResult
+		err := json.Unmarshal([]byte(tt.json), &decoded)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %+v (error %v), want an error with %q", tt.json, decoded, err, tt.want)
+		}
+	}
+
+	// In a Score, as the server sends it
+	var s bisect.Score
+	if err := json.Unmarshal([]byte(`{"Score": {"pb0": null}}`), &s); err == nil || !strings.Contains(err.Error(), "result is missing") {
+		t.Errorf("a null result got error %v", err)
+	}
+}
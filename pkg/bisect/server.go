@@ -0,0 +1,159 @@
+package bisect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+// Server is a local stand-in for the problem server, serving TestCases over the same websocket protocol
+// The test cases are shared between every connection, so several connections can work through them at once
+// and each connection gets the Score for just the problems it solved.
+type Server struct {
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	cases []*TestCase
+	next  int
+}
+
+// NewServer creates a server that hands out the test cases in order
+func NewServer(cases []*TestCase) *Server {
+	return &Server{
+		cases: cases,
+	}
+}
+
+// nextCase returns the next unsolved test case, or nil when they have all been handed out
+func (s *Server) nextCase() *TestCase {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next >= len(s.cases) {
+		return nil
+	}
+	t := s.cases[s.next]
+	s.next++
+	return t
+}
+
+// ServeHTTP upgrades the connection and plays the human until there are no test cases left
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading connection: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	err = s.serve(r.Context(), ws)
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		log.Printf("%v said goodbye before finishing", r.RemoteAddr)
+	} else if err != nil {
+		log.Printf("Error serving %v: %v", r.RemoteAddr, err)
+	}
+}
+
+// incoming is any message the client can send
+type incoming struct {
+	User      []string `json:"User"`
+	Question  *string  `json:"Question"`
+	Questions []string `json:"Questions"`
+	Solution  *string  `json:"Solution"`
+}
+
+func (s *Server) serve(ctx context.Context, ws *websocket.Conn) error {
+	var msg incoming
+
+	err := ws.ReadJSON(&msg)
+	if err != nil {
+		return err
+	}
+	if len(msg.User) != 2 {
+		return fmt.Errorf("expected authentication, got %v", msg)
+	}
+
+	score := Score{Score: make(map[string]ProblemResult)}
+
+	for t := s.nextCase(); t != nil; t = s.nextCase() {
+		name := t.Problem.Repo.Name
+
+		err = ws.WriteJSON(RepoContainer{t.Problem.Repo})
+		if err != nil {
+			return err
+		}
+		err = ws.WriteJSON(InstanceContainer{t.Problem.Instance})
+		if err != nil {
+			return err
+		}
+
+		oracle := NewLocalOracle(t)
+		questions := 0
+
+	answering:
+		for {
+			var msg incoming
+
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return err
+			}
+
+			// GiveUp is sent as a plain string
+			if string(message) == `"GiveUp"` {
+				score.addResult(name, ProblemResult{Outcome: report.GaveUp})
+				break answering
+			}
+
+			err = json.Unmarshal(message, &msg)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case msg.Question != nil:
+				questions++
+				answer, err := oracle.Ask(ctx, Question{Question: *msg.Question})
+				if err != nil {
+					return err
+				}
+				err = ws.WriteJSON(answer)
+				if err != nil {
+					return err
+				}
+			case msg.Questions != nil:
+				// Batched questions are answered all together, in the same order
+				answers := Answers{Answers: make([]string, len(msg.Questions))}
+				for i, q := range msg.Questions {
+					questions++
+					answer, err := oracle.Ask(ctx, Question{Question: q})
+					if err != nil {
+						return err
+					}
+					answers.Answers[i] = answer.Answer
+				}
+				err = ws.WriteJSON(answers)
+				if err != nil {
+					return err
+				}
+			case msg.Solution != nil:
+				if *msg.Solution == t.Bug {
+					score.addResult(name, ProblemResult{Outcome: report.Correct, Questions: questions})
+				} else {
+					score.addResult(name, ProblemResult{Outcome: report.Wrong})
+				}
+				break answering
+			default:
+				return fmt.Errorf("unexpected message %s", message)
+			}
+		}
+	}
+
+	return ws.WriteJSON(score)
+}
@@ -1,87 +1,177 @@
 package bisect
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
 )
 
-// NextMoveWebsocket actually contains the logic
-func (c *Connection) NextMoveWebsocket(d *dag.DAG, pc dag.ParamConfig, problemInstance ProblemInstance) (Score, error) {
-	var s Score
-	problemnumber := 1
-	for {
+// Oracle is anything that can answer questions about commits, normally the server pretending to be a human
+type Oracle interface {
+	Ask(ctx context.Context, q Question) (Answer, error)
+}
 
-		// IF the length is 0, submit the last "badcommit"
-		for d.GetOrder() == 0 {
-			log.Printf("👌 Submitting (%v)\n", d.MostRecentBad)
-			var err error
-			s, problemInstance, err = c.SubmitSolutionWebsocket(Solution{
-				Solution: d.MostRecentBad,
-			}, problemInstance)
-			if err != nil {
-				return Score{}, err
-			}
-			if problemInstance.Repo.Name == "" {
-				return s, err
-			}
+// Ask lets the websocket connection be used as an Oracle
+func (c *Connection) Ask(ctx context.Context, q Question) (Answer, error) {
+	return c.AskQuestionWebsocket(ctx, q)
+}
 
-			// Else, restart with the new problem
+// PrepareCandidates builds the DAG for the problem, and rules out commits with the instance's good and bad commits
+// A streamed DAG is used as the base as it is, the candidates never change it, so it stays unpruned for every instance of the repo.
+func PrepareCandidates(problemInstance ProblemInstance) (*dag.Candidates, error) {
+	d := problemInstance.DAG
+	if d == nil {
+		d = DAGMaker(&problemInstance.Repo)
+	}
 
-			d = DAGMaker(&problemInstance.Repo)
-			problemnumber++
+	log.Printf("Problem: %v has %v vertexes (commits) and %v edges\n", problemInstance.Repo.Name, d.GetOrder(), d.GetSize())
+	log.Printf("Instance's GOOD: %v, BAD: %v", problemInstance.Instance.Good, problemInstance.Instance.Bad)
 
-			log.Printf("PROGRESS: %v / ?", problemnumber)
-			log.Printf("Problem: %v has %v vertexes (commits) and %v edges\n", problemInstance.Repo.Name, d.GetOrder(), d.GetSize())
-			log.Printf("Instance's GOOD: %v, BAD: %v", problemInstance.Instance.Good, problemInstance.Instance.Bad)
+	c := dag.NewCandidates(d)
+	err := c.Good(problemInstance.Instance.Good)
+	if err != nil {
+		return nil, err
+	}
 
-			err = d.GoodCommit(problemInstance.Instance.Good)
-			if err != nil {
-				return s, err
-			}
+	log.Printf("Now %v commits after GOOD 👍 (%v)\n", c.View().GetOrder(), problemInstance.Instance.Good)
 
-			log.Printf("Now %v commits after GOOD 👍 (%v)\n", d.GetOrder(), problemInstance.Instance.Good)
+	err = c.Bad(problemInstance.Instance.Bad)
+	if err != nil {
+		return nil, err
+	}
 
-			err = d.BadCommit(problemInstance.Instance.Bad)
-			if err != nil {
-				return s, err
-			}
+	log.Printf("Now %v commits after BAD 👎 (%v)\n", c.View().GetOrder(), problemInstance.Instance.Bad)
 
-			log.Printf("Now %v commits after BAD 👎 (%v)\n", d.GetOrder(), problemInstance.Instance.Bad)
+	return c, nil
+}
 
-			// In the event they basically give us the answer, it should submit the solution??
+// Bisect keeps asking the oracle about the midpoint until there is nothing left but the most recent bad commit
+// It returns the solution (the most recent bad commit) and the number of questions asked
+// Once the context is cancelled no more questions are asked, and ctx.Err() is returned
+// If none of the candidates left can be tested it returns an UntestableError.
+func Bisect(ctx context.Context, c *dag.Candidates, pc dag.ParamConfig, o Oracle) (Solution, int, error) {
+	questions := 0
+
+	for c.View().GetEligibleOrder() > 0 {
+		if err := ctx.Err(); err != nil {
+			return Solution{}, questions, err
 		}
 
-		midpoint, err := d.GetMidPoint(pc)
+		midpoint, err := c.View().GetMidPoint(ctx, pc)
 		if err != nil {
-			return s, err
+			return Solution{}, questions, err
+		}
+		if midpoint == "" {
+			return Solution{}, questions, UntestableError{Candidates: c.Len()}
 		}
 
 		question := Question{
 			Question: midpoint,
 		}
 
+		if estimate, err := c.View().GetEstimate(midpoint); err == nil {
+			log.Printf("📏 %v candidates left, %v", estimate.Candidates, estimate)
+		}
 		log.Printf("❓Asking about %v\n", midpoint)
 
-		// ELSE get midpoint and ask question
-		answer, err := c.AskQuestionWebsocket(question)
+		answer, err := o.Ask(ctx, question)
 		if err != nil {
-			return s, err
+			return Solution{}, questions, err
 		}
+		questions++
 
 		switch answer.Answer {
 		case "Good":
-			err := d.GoodCommit(question.Question)
+			err := c.Good(question.Question)
 			if err != nil {
-				return s, err
+				return Solution{}, questions, err
 			}
-			log.Printf("Now %v commits after GOOD 👍 (%v)\n", d.GetOrder(), question.Question)
+			log.Printf("Now %v commits after GOOD 👍 (%v)\n", c.View().GetOrder(), question.Question)
 		case "Bad":
-			err := d.BadCommit(question.Question)
+			err := c.Bad(question.Question)
 			if err != nil {
-				return s, err
+				return Solution{}, questions, err
 			}
-			log.Printf("Now %v commits after BAD 👎 (%v)\n", d.GetOrder(), question.Question)
+			log.Printf("Now %v commits after BAD 👎 (%v)\n", c.View().GetOrder(), question.Question)
+		}
+	}
+
+	return Solution{
+		Solution: c.MostRecentBad(),
+	}, questions, nil
+}
+
+// UntestableError is returned by Bisect and BisectBatch when none of the candidates left can be tested
+type UntestableError struct {
+	Candidates int
+}
+
+func (e UntestableError) Error() string {
+	return fmt.Sprintf("none of the %v candidates left can be tested", e.Candidates)
+}
+
+// guessIfUntestable turns an UntestableError into a guess of the most recent bad commit, so a problem that
+// can't be finished is still submitted rather than stopping every problem after it. Any other error is returned.
+func guessIfUntestable(name string, c *dag.Candidates, solution Solution, err error) (Solution, error) {
+	var untestable UntestableError
+	if !errors.As(err, &untestable) {
+		return solution, err
+	}
+	log.Printf("🤷 %v: %v, guessing the most recent bad (%v)\n", name, err, c.MostRecentBad())
+	return Solution{Solution: c.MostRecentBad()}, nil
+}
+
+// NextMoveWebsocket actually contains the logic
+// If the context is cancelled it stops after the current question, returning ctx.Err() and a partial Score
+// of the problems submitted so far, as the server only gives us the real Score at the very end.
+func (c *Connection) NextMoveWebsocket(ctx context.Context, cands *dag.Candidates, pc dag.ParamConfig, problemInstance ProblemInstance) (Score, error) {
+	var s Score
+	partial := Score{
+		Score:  make(map[string]ProblemResult),
+		Bounds: make(map[string]dag.Bound),
+	}
+	savedDAGs := make(map[string]bool)
+	problemnumber := 1
+	for {
+		name := problemInstance.Repo.Name
+
+		bound := cands.View().GetBound(dag.ExactLimit)
+		partial.addBound(name, bound)
+		log.Printf("Problem %v has %v candidates, which needs at least %v questions", name, bound.Candidates, bound.Best())
+
+		solution, questions, err := BisectBatch(ctx, cands, pc, c, c.Batch)
+		saveBisectLog(c.Logs, problemInstance, cands, savedDAGs)
+		solution, err = guessIfUntestable(name, cands, solution, err)
+		if err != nil {
+			return partial, err
+		}
+
+		// Once the DAG is empty, submit the last "badcommit"
+		log.Printf("👌 Submitting (%v)\n", solution.Solution)
+		s, problemInstance, err = c.SubmitSolutionWebsocket(ctx, solution, problemInstance)
+		if err != nil {
+			return partial, err
+		}
+		partial.addResult(name, ProblemResult{Outcome: report.Submitted, Questions: questions})
+
+		if problemInstance.Repo.Name == "" {
+			s.Bounds = partial.Bounds
+			return s, err
+		}
+
+		// Else, restart with the new problem
+		problemnumber++
+		log.Printf("PROGRESS: %v / ?", problemnumber)
+
+		// In the event they basically give us the answer, Bisect won't ask anything and it gets submitted straight away
+		cands, err = PrepareCandidates(problemInstance)
+		if err != nil {
+			return partial, err
 		}
+		c.apply(cands)
 	}
 }
@@ -0,0 +1,255 @@
+package bisect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+)
+
+// DecodeRepoDAG reads a Repo message ({"Repo": {"name", "instance_count", "dag"}}) a token at a time,
+// adding every commit straight to a DAG, so the []DAGEntry for a huge repo never has to exist.
+// The returned Repo has the name and instance count but no Dag.
+func DecodeRepoDAG(r io.Reader) (Repo, *dag.DAG, error) {
+	dec := json.NewDecoder(r)
+
+	var repo Repo
+	var d *dag.DAG
+	err := decodeObject(dec, func(key string) error {
+		if key != "Repo" {
+			return skipValue(dec)
+		}
+		var err error
+		repo, d, _, err = decodeRepo(dec, nil)
+		return err
+	})
+	if err != nil {
+		return repo, nil, err
+	}
+	if d == nil {
+		return repo, nil, fmt.Errorf("message has no Repo")
+	}
+
+	return repo, d, nil
+}
+
+// errSkipRest stops decoding a message once everything we need from it has been read
+var errSkipRest = errors.New("the rest of the message isn't needed")
+
+// serverMessage is whichever of a Score, an Instance or a Repo the server sent
+// cached is set if the Repo's DAG came from the cache rather than the message.
+type serverMessage struct {
+	score    *Score
+	instance *Instance
+	repo     *Repo
+	dag      *dag.DAG
+	cached   bool
+}
+
+// decodeServerMessage reads any message the server sends, streaming a Repo straight into a DAG
+// If cached is given and returns a DAG for the repo's name, the dag in the message is skipped over instead,
+// and once the name and instance count have been read the rest of the message isn't looked at.
+func decodeServerMessage(r io.Reader, cached func(name string) *dag.DAG) (serverMessage, error) {
+	dec := json.NewDecoder(r)
+
+	var m serverMessage
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "Score":
+			m.score = &Score{}
+			return dec.Decode(&m.score.Score)
+		case "Instance":
+			m.instance = &Instance{}
+			return dec.Decode(m.instance)
+		case "Repo":
+			repo, d, fromCache, err := decodeRepo(dec, cached)
+			m.repo, m.dag, m.cached = &repo, d, fromCache
+			return err
+		default:
+			return skipValue(dec)
+		}
+	})
+	if err == errSkipRest {
+		err = nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if m.score == nil && m.instance == nil && m.repo == nil {
+		return m, fmt.Errorf("expected a Score, Instance or Repo message")
+	}
+
+	return m, nil
+}
+
+// decodeRepo reads the Repo object itself, using the cached DAG if there is one for its name
+// The server sends the name before the dag, otherwise the cache can't help.
+func decodeRepo(dec *json.Decoder, cached func(name string) *dag.DAG) (Repo, *dag.DAG, bool, error) {
+	var repo Repo
+	var d *dag.DAG
+	fromCache := false
+	counted := false
+
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "name":
+			return dec.Decode(&repo.Name)
+		case "instance_count":
+			counted = true
+			return dec.Decode(&repo.InstanceCount)
+		case "dag":
+			if cached != nil && repo.Name != "" {
+				if d = cached(repo.Name); d != nil {
+					fromCache = true
+					if counted {
+						return errSkipRest
+					}
+					return skipValue(dec)
+				}
+			}
+			var err error
+			d, err = decodeDAG(dec)
+			return err
+		default:
+			return skipValue(dec)
+		}
+	})
+	if err == errSkipRest {
+		return repo, d, fromCache, err
+	}
+	if err != nil {
+		return repo, nil, false, err
+	}
+	if d == nil {
+		return repo, nil, false, fmt.Errorf("repo %s has no dag", repo.Name)
+	}
+
+	return repo, d, fromCache, nil
+}
+
+// decodeDAG reads the [["commit", ["parent", ...]], ...] array into a new DAG, in the same way as DAGMaker
+// The entries are checked the same way as DAGEntry.UnmarshalJSON.
+func decodeDAG(dec *json.Decoder) (*dag.DAG, error) {
+	d := dag.NewDAG()
+
+	err := expectDelim(dec, '[', "dag")
+	if err != nil {
+		return nil, err
+	}
+
+	for dec.More() {
+		err = expectDelim(dec, '[', "dag entry")
+		if err != nil {
+			return nil, err
+		}
+
+		commit, err := decodeCommit(dec, "dag entry commit")
+		if err != nil {
+			return nil, err
+		}
+		d.AddVertex(commit)
+
+		if !dec.More() {
+			return nil, fmt.Errorf("dag entry should have 2 elements, %s has no parents", commit)
+		}
+		err = expectDelim(dec, '[', "parents of "+commit)
+		if err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			parent, err := decodeCommit(dec, "parent of "+commit)
+			if err != nil {
+				return nil, err
+			}
+			// Duplicate edges are ignored, like in DAGMaker
+			d.AddEdge(parent, commit)
+		}
+		_, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if dec.More() {
+			return nil, fmt.Errorf("dag entry should have 2 elements, %s has more", commit)
+		}
+		_, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = dec.Token()
+	return d, err
+}
+
+// decodeObject reads an object, calling field for every key with the decoder just before its value
+func decodeObject(dec *json.Decoder, field func(key string) error) error {
+	err := expectDelim(dec, '{', "message")
+	if err != nil {
+		return err
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		err = field(t.(string))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// decodeCommit reads a single non-empty commit string
+func decodeCommit(dec *json.Decoder, what string) (string, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	commit, ok := t.(string)
+	if !ok {
+		return "", fmt.Errorf("%s should be a string, got %v", what, t)
+	}
+	if commit == "" {
+		return "", fmt.Errorf("%s is empty", what)
+	}
+	return commit, nil
+}
+
+// expectDelim reads the next token, which has to be the delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim, what string) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("%s should start with %v, got %v", what, delim, t)
+	}
+	return nil
+}
+
+// skipValue reads past the next value, whatever it is, a token at a time so a huge value is never held in memory
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package bisect_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/gen"
+)
+
+func TestDecodeRepoDAGMatchesDAGMaker(t *testing.T) {
+	for _, family := range gen.Names() {
+		repo := gen.Repo(gen.Families[family], "small-"+family, 200, 1)
+		message, err := json.Marshal(bisect.RepoContainer{Repo: repo})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		streamed, d, err := bisect.DecodeRepoDAG(bytes.NewReader(message))
+		if err != nil {
+			t.Fatalf("%v: %v", family, err)
+		}
+		want := bisect.DAGMaker(&repo)
+
+		if streamed.Name != repo.Name || streamed.InstanceCount != repo.InstanceCount {
+			t.Errorf("%v: got %v (%v instances), want %v (%v)", family, streamed.Name, streamed.InstanceCount, repo.Name, repo.InstanceCount)
+		}
+		if d.GetOrder() != want.GetOrder() || d.GetSize() != want.GetSize() {
+			t.Errorf("%v: got %v commits and %v edges, want %v and %v", family, d.GetOrder(), d.GetSize(), want.GetOrder(), want.GetSize())
+		}
+		for v := range want.GetVertices() {
+			got, err := d.GetAncestorsLength(v)
+			if err != nil {
+				t.Fatalf("%v: %v", family, err)
+			}
+			if expected, _ := want.GetAncestorsLength(v); got != expected {
+				t.Fatalf("%v: %v has %v ancestors, want %v", family, v, got, expected)
+			}
+		}
+	}
+}
+
+func TestDecodeRepoDAGErrors(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{`[]`, "should start with {"},
+		{`{}`, "no Repo"},
+		{`{"Repo": {"name": "x"}}`, "has no dag"},
+		{`{"Repo": {"dag": {}}}`, "dag should start with ["},
+		{`{"Repo": {"dag": [["a"]]}}`, "has no parents"},
+		{`{"Repo": {"dag": [["a", [], []]]}}`, "has more"},
+		{`{"Repo": {"dag": [[1, []]]}}`, "commit should be a string"},
+		{`{"Repo": {"dag": [["", []]]}}`, "commit is empty"},
+		{`{"Repo": {"dag": [["a", "b"]]}}`, "parents of a should start with ["},
+		{`{"Repo": {"dag": [["a", [2]]]}}`, "parent of a should be a string"},
+		{`{"Repo": {"dag": [["a", ["b"]]`, "unexpected"},
+	}
+
+	for _, tt := range tests {
+		_, _, err := bisect.DecodeRepoDAG(strings.NewReader(tt.message))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got error %v, want %q", tt.message, err, tt.want)
+		}
+	}
+}
+
+// Firefox has a few hundred thousand commits, go test ./pkg/bisect -run '^$' -bench Decode -firefox 700000
+var firefox = flag.Int("firefox", 200000, "commits in the repo for the decoding benchmarks")
+
+var bigRepo struct {
+	once    sync.Once
+	message []byte
+}
+
+func bigMessage(b *testing.B) []byte {
+	bigRepo.once.Do(func() {
+		repo := gen.Repo(gen.Random, "big-firefox", *firefox, 1)
+		message, err := json.Marshal(bisect.RepoContainer{Repo: repo})
+		if err != nil {
+			b.Fatal(err)
+		}
+		bigRepo.message = message
+	})
+	return bigRepo.message
+}
+
+// liveHeap is how much memory is in use once the garbage is collected
+func liveHeap() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// reportLive reports how much memory is still held once decoding is done, on top of the message itself
+func reportLive(b *testing.B, before uint64, keep ...interface{}) {
+	after := liveHeap()
+	runtime.KeepAlive(keep)
+	b.ReportMetric(float64(after-before)/(1<<20), "live-MB")
+}
+
+// BenchmarkDecodeRepoUnmarshal is the old way, the whole []DAGEntry and then DAGMaker, which keeps both
+func BenchmarkDecodeRepoUnmarshal(b *testing.B) {
+	message := bigMessage(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(message)))
+	b.ResetTimer()
+
+	var repo bisect.RepoContainer
+	var d *dag.DAG
+	before := liveHeap()
+	for i := 0; i < b.N; i++ {
+		repo = bisect.RepoContainer{}
+		err := json.Unmarshal(message, &repo)
+		if err != nil {
+			b.Fatal(err)
+		}
+		d = bisect.DAGMaker(&repo.Repo)
+	}
+	b.StopTimer()
+	reportLive(b, before, repo, d)
+}
+
+func BenchmarkDecodeRepoStream(b *testing.B) {
+	message := bigMessage(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(message)))
+	b.ResetTimer()
+
+	var d *dag.DAG
+	before := liveHeap()
+	for i := 0; i < b.N; i++ {
+		var err error
+		_, d, err = bisect.DecodeRepoDAG(bytes.NewReader(message))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	reportLive(b, before, d)
+}
@@ -1,15 +1,15 @@
 package bisect
 
 import (
+	"context"
 	"encoding/json"
-	"flag"
+	"fmt"
 	"log"
 	"net/url"
-	"os"
-	"os/signal"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
 )
 
 // Authentication is the first message sent to the server
@@ -17,32 +17,95 @@ type Authentication struct {
 	User []string `json:"User"`
 }
 
+// Options are the optional extras for solving problems, any of which can be left empty
+type Options struct {
+	// Cache keeps the DAG of every repo the server sends
+	Cache *RepoCache
+	// Logs is a directory to save the bisect log of every instance in
+	Logs string
+	// Weights are the prior weights of the commits, for the midpoint selection to split
+	Weights dag.Weights
+	// Costs are how expensive the commits are to test, for the midpoint selection to keep down
+	Costs dag.Costs
+	// Batch is how many questions to ask at once, see BisectBatch. 0 or 1 asks them one at a time.
+	Batch int
+}
+
+// apply gives the candidates the weights and costs
+func (o Options) apply(c *dag.Candidates) {
+	c.SetWeights(o.Weights)
+	c.SetCosts(o.Costs)
+}
+
 // Connection is the websocket connection
 type Connection struct {
 	WS      *websocket.Conn
 	Timeout time.Duration
+	Options
 }
 
 // ConnectWebsocket connects to the websocket server, and returns the problem
-func ConnectWebsocket(u url.URL, t time.Duration) (*Connection, error) {
-	flag.Parse()
+func ConnectWebsocket(ctx context.Context, u url.URL, t time.Duration) (*Connection, error) {
+	c, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
 
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
+	return &Connection{WS: c, Timeout: t}, nil
+}
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+// readServerMessage reads the next message from the server, streaming any Repo straight into a DAG,
+// or loading it from the cache if we have seen it before
+func (c *Connection) readServerMessage() (serverMessage, error) {
+	_, r, err := c.WS.NextReader()
 	if err != nil {
-		return nil, err
+		return serverMessage{}, err
+	}
+	if c.Cache == nil {
+		return decodeServerMessage(r, nil)
 	}
 
-	return &Connection{c, t}, nil
+	var m serverMessage
+	var key string
+	if c.Cache.ByName {
+		m, err = decodeServerMessage(r, c.Cache.Lookup)
+		if m.repo != nil {
+			key = m.repo.Name
+		}
+	} else {
+		m, key, err = c.Cache.decodeHashed(r)
+	}
+	if err != nil {
+		return m, err
+	}
+
+	if m.repo != nil && !m.cached {
+		c.Cache.Store(key, m.dag)
+	}
+	return m, nil
+}
+
+// Close says goodbye to the server with a close frame, before closing the connection
+func (c *Connection) Close() error {
+	err := c.WS.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second),
+	)
+	if err != nil {
+		log.Printf("Error sending close frame: %v", err)
+	}
+	return c.WS.Close()
 }
 
 // GetProblemWebsocket simply returns the problem, given an authentication
-func (c *Connection) GetProblemWebsocket(a Authentication) (ProblemInstance, error) {
+func (c *Connection) GetProblemWebsocket(ctx context.Context, a Authentication) (ProblemInstance, error) {
 	var prob ProblemInstance
 
-	var repo RepoContainer
+	if err := ctx.Err(); err != nil {
+		return prob, err
+	}
+
 	var inst InstanceContainer
 
 	jsona, err := json.Marshal(a)
@@ -56,16 +119,13 @@ func (c *Connection) GetProblemWebsocket(a Authentication) (ProblemInstance, err
 		return prob, err
 	}
 
-	// Retrieve the Initial Repo
-	_, message, err := c.WS.ReadMessage()
+	// Retrieve the Initial Repo, straight into a DAG as it can be huge
+	m, err := c.readServerMessage()
 	if err != nil {
 		return prob, err
 	}
-
-	// Parse the Repo message
-	err = json.Unmarshal(message, &repo)
-	if err != nil {
-		return prob, err
+	if m.repo == nil {
+		return prob, fmt.Errorf("expected a Repo after authenticating")
 	}
 
 	// Retrieve the instance message
@@ -81,7 +141,8 @@ func (c *Connection) GetProblemWebsocket(a Authentication) (ProblemInstance, err
 	}
 
 	// If there are no issues, then return the new problem instance
-	prob.Repo = repo.Repo
+	prob.Repo = *m.repo
+	prob.DAG = m.dag
 	prob.Instance = inst.Instance
 
 	// log.Print(string(message))
@@ -90,9 +151,14 @@ func (c *Connection) GetProblemWebsocket(a Authentication) (ProblemInstance, err
 }
 
 // AskQuestionWebsocket asks a question about this particular commit to the server
-func (c *Connection) AskQuestionWebsocket(q Question) (Answer, error) {
+// A question that has already been sent is always waited for, the context only stops new ones being asked
+func (c *Connection) AskQuestionWebsocket(ctx context.Context, q Question) (Answer, error) {
 	var ans Answer
 
+	if err := ctx.Err(); err != nil {
+		return ans, err
+	}
+
 	jsonq, err := json.Marshal(q)
 	if err != nil {
 		return ans, err
@@ -121,15 +187,59 @@ func (c *Connection) AskQuestionWebsocket(q Question) (Answer, error) {
 	return ans, nil
 }
 
+// AskQuestionsWebsocket asks about several commits in one message, and gets all of the answers back in one
+// The real server doesn't understand this, only the local one does.
+func (c *Connection) AskQuestionsWebsocket(ctx context.Context, qs Questions) (Answers, error) {
+	var ans Answers
+
+	if err := ctx.Err(); err != nil {
+		return ans, err
+	}
+
+	jsonq, err := json.Marshal(qs)
+	if err != nil {
+		return ans, err
+	}
+
+	c.WS.SetWriteDeadline(time.Now().Add(c.Timeout))
+	c.WS.SetReadDeadline(time.Now().Add(c.Timeout))
+
+	err = c.WS.WriteMessage(websocket.TextMessage, jsonq)
+	if err != nil {
+		log.Printf("Error writing questions")
+		return ans, err
+	}
+
+	_, message, err := c.WS.ReadMessage()
+	if err != nil {
+		log.Printf("Error retrieving the answers to the questions")
+		return ans, err
+	}
+
+	err = json.Unmarshal(message, &ans)
+	if err != nil {
+		return ans, err
+	}
+	if len(ans.Answers) != len(qs.Questions) {
+		return ans, fmt.Errorf("asked %v questions, but got %v answers", len(qs.Questions), len(ans.Answers))
+	}
+
+	return ans, nil
+}
+
 // SubmitSolutionWebsocket is the "endpoint" where you can submit a solution
 // It can either return a score, or an instance, or a new repo, which is then followed by an instance.
 // Really intuitive and simple?
-func (c *Connection) SubmitSolutionWebsocket(attempt Solution, currentProb ProblemInstance) (Score, ProblemInstance, error) {
+// Once a solution is sent the reply is always waited for, so the context only stops the solution being sent at all.
+func (c *Connection) SubmitSolutionWebsocket(ctx context.Context, attempt Solution, currentProb ProblemInstance) (Score, ProblemInstance, error) {
 	var scor Score
 	var prob ProblemInstance
-	var repo RepoContainer
 	var inst InstanceContainer
 
+	if err := ctx.Err(); err != nil {
+		return scor, prob, err
+	}
+
 	c.WS.SetWriteDeadline(time.Now().Add(c.Timeout))
 	c.WS.SetReadDeadline(time.Now().Add(c.Timeout))
 
@@ -146,56 +256,47 @@ func (c *Connection) SubmitSolutionWebsocket(attempt Solution, currentProb Probl
 		return scor, prob, err
 	}
 
-	// Retrieve the response
-	_, message, err := c.WS.ReadMessage()
+	// Retrieve the response, which could be a whole new Repo so is streamed
+	m, err := c.readServerMessage()
 	if err != nil {
 		log.Printf("Error retrieving solution answer")
 		return scor, prob, err
 	}
 
-	// Assume it is the score
-	err = json.Unmarshal(message, &scor)
-	if err != nil || len(scor.Score) == 0 {
+	switch {
+	case m.score != nil && len(m.score.Score) > 0:
+		scor = *m.score
 
-		// Attempt to get the instance
-		err = json.Unmarshal(message, &inst)
-		if err != nil || inst.Instance.Good == "" {
-
-			// Attempt to retrieve repo
-			err = json.Unmarshal(message, &repo)
-			if err != nil {
-				return scor, prob, err
-			}
-
-			// Now get the instance
-			_, instmessage, err := c.WS.ReadMessage()
-			if err != nil {
-				log.Printf("Error retrieving new instance")
-				return scor, prob, err
-			}
-
-			// Attempt to retrieve instance message
-			err = json.Unmarshal(instmessage, &inst)
-			if err != nil {
-				return scor, prob, err
-			}
+	case m.instance != nil:
+		prob.Repo = currentProb.Repo
+		prob.DAG = currentProb.DAG
+		prob.Instance = *m.instance
 
-			// Now build the new problem
-			prob.Repo = repo.Repo
-			prob.Instance = inst.Instance
+		log.Printf("Retrieved new INSTANCE for: %v", prob.Repo.Name)
 
-			log.Printf("Retrieved new PROBLEM: %v", prob.Repo.Name)
+	case m.repo != nil:
+		// Now get the instance
+		_, instmessage, err := c.WS.ReadMessage()
+		if err != nil {
+			log.Printf("Error retrieving new instance")
+			return scor, prob, err
+		}
 
-			// Return the new problem
-			return scor, prob, nil
+		// Attempt to retrieve instance message
+		err = json.Unmarshal(instmessage, &inst)
+		if err != nil {
+			return scor, prob, err
 		}
-		prob.Repo = currentProb.Repo
+
+		// Now build the new problem
+		prob.Repo = *m.repo
+		prob.DAG = m.dag
 		prob.Instance = inst.Instance
 
-		log.Printf("Retrieved new INSTANCE for: %v", prob.Repo.Name)
+		log.Printf("Retrieved new PROBLEM: %v", prob.Repo.Name)
 
-		// Return the new instance of the same problem
-		return scor, prob, nil
+	default:
+		return scor, prob, fmt.Errorf("expected a Score, Instance or Repo after the solution")
 	}
 
 	// Return the final score
@@ -0,0 +1,398 @@
+// Package config loads the settings for talking to the problem server.
+// Settings come from (in increasing priority) the defaults, a JSON/YAML/TOML config file,
+// GITBISECT_* environment variables and finally the command line flags.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+// EnvPrefix is prepended to the upper case key to get the environment variable, e.g. GITBISECT_PARAMS_LIMIT
+const EnvPrefix = "GITBISECT_"
+
+// Config is everything cmd/fromwebsockets needs to know
+// The token is deliberately never read from the config file or flags, only from a file or the environment
+type Config struct {
+	Addr       string          `json:"addr"`
+	User       string          `json:"user"`
+	Token      string          `json:"-"`
+	TokenFile  string          `json:"token_file,omitempty"`
+	Timeout    time.Duration   `json:"-"`
+	Params     dag.ParamConfig `json:"params"`
+	ParamsFile string          `json:"params_file,omitempty"`
+
+	// Connections is how many problems to solve at once, each on its own connection (or worker, if Local is set)
+	Connections int `json:"connections"`
+	// Local is a glob of test problems to solve in process, instead of connecting to the server
+	Local string `json:"local,omitempty"`
+
+	// Results is where to save the report, in ResultsFormat (or guessed from the extension if that is empty)
+	Results       string `json:"results"`
+	ResultsFormat string `json:"results_format,omitempty"`
+	// History is a results history file to append the run to, for cmd/history to compare
+	History string `json:"history,omitempty"`
+
+	// DAGCache is a directory to keep the DAG of every repo the server sends in, matched by content hash
+	// or by name if DAGCacheByName is set
+	DAGCache       string `json:"dag_cache,omitempty"`
+	DAGCacheByName bool   `json:"dag_cache_by_name,omitempty"`
+
+	// BisectLogs is a directory to save the bisect log of every instance in, for cmd/replay
+	BisectLogs string `json:"bisect_logs,omitempty"`
+	// Weights is a json file of prior weights for commits that are more (or less) likely to be the culprit
+	Weights string `json:"weights,omitempty"`
+	// Costs is a json file of how expensive commits are to test, or "untestable" if they can't be
+	Costs string `json:"costs,omitempty"`
+	// Batch is how many questions to ask at once, which only works with Local (1 asks them one at a time)
+	Batch int `json:"batch"`
+
+	// PrintConfig is only set by the flag, and means print the config and exit
+	PrintConfig bool `json:"-"`
+}
+
+// Default returns the settings that were originally hard-coded
+func Default() *Config {
+	return &Config{
+		Addr:        "129.12.44.246:1234",
+		Timeout:     time.Minute * 30,
+		Connections: 1,
+		Batch:       1,
+		Results:     "results.txt",
+		Params: dag.ParamConfig{
+			Limit:     5000,
+			Divisions: 50,
+			Merges:    100,
+			Strategy:  dag.StrategyAuto,
+		},
+	}
+}
+
+// keys are all the settings that can be given in a file, environment variable or flag
+var keys = []string{
+	"addr",
+	"user",
+	"token_file",
+	"timeout",
+	"strategy",
+	"params_file",
+	"params.limit",
+	"params.divisions",
+	"params.merges",
+	"connections",
+	"local",
+	"results",
+	"results_format",
+	"history",
+	"dag_cache",
+	"dag_cache_by_name",
+	"bisect_logs",
+	"weights",
+	"costs",
+	"batch",
+}
+
+// overlay is a single setting, and where it came from (empty for a flag)
+type overlay struct {
+	source string
+	key    string
+	value  string
+}
+
+func sortedKeys(settings map[string]string) []string {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Set sets a single setting from its string form
+func (c *Config) Set(key, value string) error {
+	var err error
+
+	switch key {
+	case "addr":
+		c.Addr = value
+	case "user":
+		c.User = value
+	case "token_file":
+		c.TokenFile = value
+	case "timeout":
+		c.Timeout, err = time.ParseDuration(value)
+	case "strategy", "params.strategy":
+		c.Params.Strategy = value
+	case "params_file":
+		c.ParamsFile = value
+	case "params.limit":
+		c.Params.Limit, err = strconv.Atoi(value)
+	case "params.divisions":
+		c.Params.Divisions, err = strconv.Atoi(value)
+	case "params.merges":
+		c.Params.Merges, err = strconv.Atoi(value)
+	case "connections":
+		c.Connections, err = strconv.Atoi(value)
+	case "local":
+		c.Local = value
+	case "results":
+		c.Results = value
+	case "results_format":
+		c.ResultsFormat = value
+	case "history":
+		c.History = value
+	case "dag_cache":
+		c.DAGCache = value
+	case "dag_cache_by_name":
+		c.DAGCacheByName, err = strconv.ParseBool(value)
+	case "bisect_logs":
+		c.BisectLogs = value
+	case "weights":
+		c.Weights = value
+	case "costs":
+		c.Costs = value
+	case "batch":
+		c.Batch, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown setting '%s'", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad value for '%s': %v", key, err)
+	}
+	return nil
+}
+
+// Load works out the config from the given command line arguments, the environment and any config file
+func Load(name string, args []string, getenv func(string) string) (*Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", getenv(EnvPrefix+"CONFIG"), "config file (.json, .yaml, .yml or .toml)")
+	fs.String("addr", c.Addr, "problem server address")
+	fs.String("user", c.User, "user to authenticate as")
+	fs.String("token-file", "", "file containing the access token (or set "+EnvPrefix+"TOKEN)")
+	fs.String("timeout", c.Timeout.String(), "timeout for each question and solution")
+	fs.String("strategy", c.Params.Strategy, "midpoint strategy, one of "+strings.Join(dag.Strategies, ", "))
+	fs.String("params", "", "json ParamConfig to use, such as the one written by cmd/tune")
+	fs.String("limit", strconv.Itoa(c.Params.Limit), "DAG size above which the midpoint is estimated")
+	fs.String("divisions", strconv.Itoa(c.Params.Divisions), "number of samples for the estimated midpoint")
+	fs.String("merges", strconv.Itoa(c.Params.Merges), "number of merges for the estimated midpoint")
+	fs.String("connections", strconv.Itoa(c.Connections), "number of problems to solve at once")
+	fs.String("local", "", "glob of test problems to solve locally instead of connecting, e.g. tests/*.json")
+	fs.String("results", c.Results, "file to save the results report to")
+	fs.String("results-format", "", "results report format, one of "+strings.Join(report.Formats, ", ")+" (default: from the extension)")
+	fs.String("history", "", "results history file to append the run to, e.g. history.jsonl")
+	fs.String("dag-cache", "", "directory to cache the DAG of every repo in, e.g. .dagcache")
+	fs.Bool("dag-cache-by-name", false, "match cached repos by name instead of by content hash")
+	fs.String("bisect-logs", "", "directory to save the bisect log of every instance in, e.g. logs")
+	fs.String("weights", "", "json file of prior weights for the commits, {\"<sha>\": 2.5, ...}")
+	fs.String("costs", "", "json file of how expensive the commits are to test, {\"<sha>\": 10, \"<sha>\": \"untestable\", ...}")
+	fs.String("batch", strconv.Itoa(c.Batch), "number of questions to ask at once, which only works with -local")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the resulting config and exit")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every setting from the config file, the environment and the flags, in increasing priority
+	var overlays []overlay
+
+	// The config file
+	if *configFile != "" {
+		settings, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range sortedKeys(settings) {
+			overlays = append(overlays, overlay{*configFile, key, settings[key]})
+		}
+	}
+
+	// The environment
+	for _, key := range keys {
+		env := envName(key)
+		if value := getenv(env); value != "" {
+			overlays = append(overlays, overlay{env, key, value})
+		}
+	}
+
+	// The flags that were actually given
+	fs.Visit(func(f *flag.Flag) {
+		key := f.Name
+		switch f.Name {
+		case "config", "print-config":
+			return
+		case "token-file":
+			key = "token_file"
+		case "params":
+			key = "params_file"
+		case "results-format":
+			key = "results_format"
+		case "dag-cache":
+			key = "dag_cache"
+		case "dag-cache-by-name":
+			key = "dag_cache_by_name"
+		case "bisect-logs":
+			key = "bisect_logs"
+		case "limit", "divisions", "merges":
+			key = "params." + f.Name
+		}
+		overlays = append(overlays, overlay{"", key, f.Value.String()})
+	})
+
+	// A params file replaces the default params, but keeps the strategy if it doesn't have one.
+	// It goes underneath everything else, so any other setting of the params still wins.
+	for _, o := range overlays {
+		if o.key == "params_file" {
+			c.ParamsFile = o.value
+		}
+	}
+	if c.ParamsFile != "" {
+		params, err := dag.LoadParamConfig(c.ParamsFile)
+		if err != nil {
+			return nil, err
+		}
+		if params.Strategy == "" {
+			params.Strategy = c.Params.Strategy
+		}
+		c.Params = params
+	}
+
+	for _, o := range overlays {
+		err = c.Set(o.key, o.value)
+		if err != nil && o.source != "" {
+			return nil, fmt.Errorf("%s: %v", o.source, err)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// The token
+	c.Token = getenv(EnvPrefix + "TOKEN")
+	if c.TokenFile != "" {
+		token, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Token = strings.TrimSpace(string(token))
+	}
+
+	return c, nil
+}
+
+// Validate checks the config makes sense, before we go and connect with it
+func (c *Config) Validate() error {
+	if c.Connections <= 0 {
+		return fmt.Errorf("connections: must be positive, got %v", c.Connections)
+	}
+	if c.Batch <= 0 {
+		return fmt.Errorf("batch: must be positive, got %v", c.Batch)
+	}
+	if c.Batch > 1 && c.Local == "" {
+		return fmt.Errorf("batch: the server only takes one question at a time, so batches need local")
+	}
+	if c.Results == "" {
+		return fmt.Errorf("results: must be set")
+	}
+	if c.ResultsFormat != "" {
+		valid := false
+		for _, f := range report.Formats {
+			valid = valid || c.ResultsFormat == f
+		}
+		if !valid {
+			return fmt.Errorf("results_format: must be one of %s, got '%s'", strings.Join(report.Formats, ", "), c.ResultsFormat)
+		}
+	}
+	if c.Local != "" {
+		return ValidateParams(c.Params)
+	}
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return fmt.Errorf("addr: %v", err)
+	}
+	if c.User == "" {
+		return fmt.Errorf("user: must be set")
+	}
+	if c.Token == "" {
+		return fmt.Errorf("token: must be set with token_file or %sTOKEN", EnvPrefix)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout: must be positive, got %v", c.Timeout)
+	}
+	return ValidateParams(c.Params)
+}
+
+// ValidateParams checks the ParamConfig won't break the midpoint selection
+func ValidateParams(p dag.ParamConfig) error {
+	valid := p.Strategy == ""
+	for _, s := range dag.Strategies {
+		valid = valid || p.Strategy == s
+	}
+	if !valid {
+		return fmt.Errorf("strategy: must be one of %s, got '%s'", strings.Join(dag.Strategies, ", "), p.Strategy)
+	}
+	if p.Limit < 0 {
+		return fmt.Errorf("params.limit: must not be negative, got %v", p.Limit)
+	}
+	if p.Divisions <= 0 {
+		return fmt.Errorf("params.divisions: must be positive, got %v", p.Divisions)
+	}
+	if p.Merges < 0 {
+		return fmt.Errorf("params.merges: must not be negative, got %v", p.Merges)
+	}
+	return nil
+}
+
+// String prints the config as JSON, without the token
+func (c *Config) String() string {
+	printable := struct {
+		*Config
+		Timeout string `json:"timeout"`
+		Token   string `json:"token"`
+	}{c, c.Timeout.String(), ""}
+
+	if c.Token != "" {
+		printable.Token = "(redacted)"
+	}
+
+	data, err := json.MarshalIndent(printable, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_").Replace(key))
+}
+
+// readFile reads the config file into "dotted.key" -> value pairs, based on its extension
+func readFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseJSON(data)
+	case ".yaml", ".yml":
+		return parseYAML(data)
+	case ".toml":
+		return parseTOML(data)
+	default:
+		return nil, fmt.Errorf("%s: unknown config format, use .json, .yaml, .yml or .toml", path)
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// write puts the file in a temporary directory, returning its path
+func write(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+// Every setting comes from the highest of: defaults < params file < config file < environment < flags
+func TestLoadPrecedence(t *testing.T) {
+	params := write(t, "params.json", `{"limit": 100, "divisions": 7, "merges": 8}`)
+	file := write(t, "config.yaml", "user: from-file\ntimeout: 1m\nparams:\n  divisions: 9\n  merges: 10\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want func(c *Config) bool
+	}{
+		{"defaults", nil, nil, func(c *Config) bool {
+			return c.User == "" && c.Params.Limit == 5000 && c.Timeout.String() == "30m0s"
+		}},
+		{"params file over defaults", []string{"-params", params}, nil, func(c *Config) bool {
+			return c.Params.Limit == 100 && c.Params.Divisions == 7 && c.Params.Strategy == "auto"
+		}},
+		{"flag over params file", []string{"-params", params, "-limit", "42"}, nil, func(c *Config) bool {
+			return c.Params.Limit == 42 && c.Params.Divisions == 7
+		}},
+		{"config file over params file", []string{"-config", file, "-params", params}, nil, func(c *Config) bool {
+			return c.User == "from-file" && c.Params.Limit == 100 && c.Params.Divisions == 9 && c.Params.Merges == 10
+		}},
+		{"env over config file", []string{"-config", file}, map[string]string{"GITBISECT_USER": "from-env", "GITBISECT_PARAMS_MERGES": "11"}, func(c *Config) bool {
+			return c.User == "from-env" && c.Params.Merges == 11 && c.Timeout.String() == "1m0s"
+		}},
+		{"flag over env", []string{"-config", file, "-user", "from-flag", "-merges", "12"}, map[string]string{"GITBISECT_USER": "from-env", "GITBISECT_PARAMS_MERGES": "11"}, func(c *Config) bool {
+			return c.User == "from-flag" && c.Params.Merges == 12
+		}},
+		{"params file from the env", []string{"-divisions", "3"}, map[string]string{"GITBISECT_PARAMS_FILE": params}, func(c *Config) bool {
+			return c.ParamsFile == params && c.Params.Limit == 100 && c.Params.Divisions == 3
+		}},
+		{"bool from a json config", []string{"-config", write(t, "bool.json", `{"dag_cache": ".cache", "dag_cache_by_name": true}`)}, nil, func(c *Config) bool {
+			return c.DAGCache == ".cache" && c.DAGCacheByName
+		}},
+		{"token from the env", nil, map[string]string{"GITBISECT_TOKEN": "secret"}, func(c *Config) bool {
+			return c.Token == "secret"
+		}},
+	}
+	for _, tt := range tests {
+		c, err := Load("test", tt.args, env(tt.env))
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if !tt.want(c) {
+			t.Errorf("%v: got %v", tt.name, c)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	file := write(t, "config.json", `{"connections": "lots"}`)
+
+	tests := []struct {
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{[]string{"-config", file}, nil, file + ": bad value for 'connections'"},
+		{nil, map[string]string{"GITBISECT_TIMEOUT": "soon"}, "GITBISECT_TIMEOUT: bad value for 'timeout'"},
+		{[]string{"-limit", "x"}, nil, "bad value for 'params.limit'"},
+		{[]string{"-config", "config.ini"}, nil, "no such file"},
+		{[]string{"-params", "missing.json"}, nil, "no such file"},
+	}
+	for _, tt := range tests {
+		_, err := Load("test", tt.args, env(tt.env))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v %v: got %v, want %q", tt.args, tt.env, err, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"server", func(c *Config) {}, ""},
+		{"local", func(c *Config) { c.Local, c.Token = "tests/*.json", "" }, ""},
+		{"local batch", func(c *Config) { c.Local, c.Batch = "tests/*.json", 4 }, ""},
+		{"server batch", func(c *Config) { c.Batch = 4 }, "batches need local"},
+		{"no batch", func(c *Config) { c.Local, c.Batch = "tests/*.json", 0 }, "batch: must be positive"},
+		{"no token", func(c *Config) { c.Token = "" }, "token: must be set"},
+		{"no connections", func(c *Config) { c.Connections = 0 }, "connections: must be positive"},
+		{"bad format", func(c *Config) { c.ResultsFormat = "yaml" }, "results_format: must be one of"},
+	}
+	for _, tt := range tests {
+		c := Default()
+		c.User, c.Token = "me", "anything"
+		tt.change(c)
+
+		err := c.Validate()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%v: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%v: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
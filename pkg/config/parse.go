@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The config is flat apart from "params", so these only understand one level of nesting, which is all we need
+
+// parseJSON flattens a JSON object into dotted keys
+func parseJSON(data []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]string)
+	err = flatten("", raw, settings)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func flatten(prefix string, raw map[string]interface{}, settings map[string]string) error {
+	for key, value := range raw {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if prefix != "" {
+				return fmt.Errorf("'%s%s' is nested too deeply", prefix, key)
+			}
+			err := flatten(key+".", v, settings)
+			if err != nil {
+				return err
+			}
+		case string:
+			settings[prefix+key] = v
+		case json.Number:
+			settings[prefix+key] = v.String()
+		case bool:
+			settings[prefix+key] = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("'%s%s' has an unsupported value %v", prefix, key, value)
+		}
+	}
+	return nil
+}
+
+// parseYAML reads "key: value" lines, where an indented block under "section:" becomes "section.key"
+func parseYAML(data []byte) (map[string]string, error) {
+	settings := make(map[string]string)
+	section := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := stripComment(scanner.Text())
+		if strings.TrimSpace(text) == "" || strings.TrimSpace(text) == "---" {
+			continue
+		}
+
+		indented := text[0] == ' ' || text[0] == '\t'
+		colon := strings.Index(text, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("line %v: expected 'key: value'", line)
+		}
+		key := strings.TrimSpace(text[:colon])
+		value := strings.TrimSpace(text[colon+1:])
+
+		if !indented {
+			section = ""
+			if value == "" {
+				section = key
+				continue
+			}
+		} else if section == "" {
+			return nil, fmt.Errorf("line %v: unexpected indentation", line)
+		}
+
+		value, err := unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		settings[join(section, key)] = value
+	}
+
+	return settings, scanner.Err()
+}
+
+// parseTOML reads "key = value" lines, where keys under a "[section]" table become "section.key"
+func parseTOML(data []byte) (map[string]string, error) {
+	settings := make(map[string]string)
+	section := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+
+		equals := strings.Index(text, "=")
+		if equals < 0 {
+			return nil, fmt.Errorf("line %v: expected 'key = value'", line)
+		}
+		key := strings.TrimSpace(text[:equals])
+
+		value, err := unquote(strings.TrimSpace(text[equals+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		settings[join(section, key)] = value
+	}
+
+	return settings, scanner.Err()
+}
+
+// stripComment removes anything after a # that isn't inside quotes
+func stripComment(line string) string {
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+func unquote(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+	if strings.HasPrefix(value, "\"") {
+		return strconv.Unquote(value)
+	}
+	return value, nil
+}
+
+func join(section, key string) string {
+	if section == "" {
+		return key
+	}
+	return section + "." + key
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]string, error)
+		data  string
+		want  map[string]string
+	}{
+		{"json", parseJSON, `{"user": "me", "connections": 4, "params": {"limit": 20, "strategy": "exact"}}`,
+			map[string]string{"user": "me", "connections": "4", "params.limit": "20", "params.strategy": "exact"}},
+		{"json bools", parseJSON, `{"dag_cache_by_name": true, "params": {"x": false}}`,
+			map[string]string{"dag_cache_by_name": "true", "params.x": "false"}},
+		{"json empty values", parseJSON, `{"timeout": "1m30s", "weights": "", "params": {}}`,
+			map[string]string{"timeout": "1m30s", "weights": ""}},
+
+		{"yaml", parseYAML, "---\nuser: me # who\nconnections: 4\nparams:\n  limit: 20\n\tstrategy: exact\naddr: localhost:1234\n",
+			map[string]string{"user": "me", "connections": "4", "params.limit": "20", "params.strategy": "exact", "addr": "localhost:1234"}},
+		{"yaml quoting", parseYAML, "user: \"me # not a comment\"\nresults: 'a b.txt' # a comment\nlocal: \"tests/\\\"x\\\".json\"\n",
+			map[string]string{"user": "me # not a comment", "results": "a b.txt", "local": `tests/"x".json`}},
+		{"yaml comments", parseYAML, "# the whole line\n\n   \nuser: me\n",
+			map[string]string{"user": "me"}},
+
+		{"toml", parseTOML, "user = \"me\"\nconnections = 4\n\n[params]\nlimit = 20 # small\nstrategy = 'exact'\n",
+			map[string]string{"user": "me", "connections": "4", "params.limit": "20", "params.strategy": "exact"}},
+		{"toml quoting", parseTOML, "user = \"me # not a comment\" # a comment\nresults = 'a = b.txt'\n",
+			map[string]string{"user": "me # not a comment", "results": "a = b.txt"}},
+		{"toml sections", parseTOML, "[params]\nlimit = 1\n[ other ]\nkey = 2\n",
+			map[string]string{"params.limit": "1", "other.key": "2"}},
+	}
+	for _, tt := range tests {
+		got, err := tt.parse([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]string, error)
+		data  string
+		want  string
+	}{
+		{"json syntax", parseJSON, `{"user": }`, "invalid character"},
+		{"json not an object", parseJSON, `["user"]`, "cannot unmarshal array"},
+		{"json too deep", parseJSON, `{"params": {"limit": {"x": 1}}}`, "'params.limit' is nested too deeply"},
+		{"json list", parseJSON, `{"user": ["a", "b"]}`, "'user' has an unsupported value [a b]"},
+		{"json null", parseJSON, `{"params": {"limit": null}}`, "'params.limit' has an unsupported value <nil>"},
+
+		{"yaml no colon", parseYAML, "user: me\njust words\n", "line 2: expected 'key: value'"},
+		{"yaml indented", parseYAML, "  user: me\n", "line 1: unexpected indentation"},
+		{"yaml bad quote", parseYAML, "user: \"me\n", "line 1: invalid syntax"},
+
+		{"toml no equals", parseTOML, "user = \"me\"\n[params]\nlimit 20\n", "line 3: expected 'key = value'"},
+		{"toml bad quote", parseTOML, "user = \"me\\q\"\n", "line 1: invalid syntax"},
+	}
+	for _, tt := range tests {
+		_, err := tt.parse([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	for _, name := range []string{"c.json", "c.YAML", "c.yml", "c.toml"} {
+		content := "user: me\n"
+		switch {
+		case strings.HasSuffix(name, ".json"):
+			content = `{"user": "me"}`
+		case strings.HasSuffix(name, ".toml"):
+			content = "user = 'me'\n"
+		}
+		got, err := readFile(write(t, name, content))
+		if err != nil || got["user"] != "me" {
+			t.Errorf("%v: got %v (%v)", name, got, err)
+		}
+	}
+
+	if _, err := readFile(write(t, "c.ini", "user=me")); err == nil || !strings.Contains(err.Error(), "unknown config format") {
+		t.Errorf("got %v for an .ini file", err)
+	}
+}
@@ -0,0 +1,164 @@
+package dag
+
+import (
+	"context"
+	"math"
+)
+
+// partition groups the commits by the answers they would give to the questions so far,
+// where a commit is in a cell with every other commit that would be the culprit for the same answers
+type partition struct {
+	cell  map[string]int
+	mass  []float64
+	total float64
+}
+
+// newPartition puts every commit in the one cell, as nothing has been asked yet. The caller must hold the read lock.
+func (d *DAG) newPartition() *partition {
+	p := &partition{cell: make(map[string]int, len(d.vertices))}
+	for v := range d.vertices {
+		p.cell[v] = 0
+		p.total += d.weight(v)
+	}
+	p.mass = []float64{p.total}
+	return p
+}
+
+// split is how much of each cell would be answered bad by q, which is q and its ancestors,
+// along with those commits. The caller must hold the read lock.
+func (d *DAG) split(p *partition, q string) (map[int]float64, []string) {
+	bad := map[int]float64{p.cell[q]: d.weight(q)}
+	members := []string{q}
+	d.visitAncestors(q, func(ancestor string) {
+		bad[p.cell[ancestor]] += d.weight(ancestor)
+		members = append(members, ancestor)
+	})
+	return bad, members
+}
+
+// gain is how much information (in bits) asking q would add to the partition
+func (p *partition) gain(bad map[int]float64) float64 {
+	h := func(x float64) float64 {
+		if x <= 0 || p.total <= 0 {
+			return 0
+		}
+		return -x / p.total * math.Log2(x/p.total)
+	}
+
+	gain := 0.0
+	for cell, in := range bad {
+		gain += h(in) + h(p.mass[cell]-in) - h(p.mass[cell])
+	}
+	return gain
+}
+
+// refine splits every cell q cuts through in two. The caller must hold the read lock.
+func (d *DAG) refine(p *partition, q string) {
+	bad, members := d.split(p, q)
+	moved := make(map[int]int)
+	for cell, in := range bad {
+		if in > 0 && in < p.mass[cell] {
+			moved[cell] = len(p.mass)
+			p.mass = append(p.mass, in)
+			p.mass[cell] -= in
+		}
+	}
+	for _, v := range members {
+		if to, ok := moved[p.cell[v]]; ok {
+			p.cell[v] = to
+		}
+	}
+}
+
+// sample is the commits GetMidPoints looks at, which is all of them when the midpoint would be exact,
+// or else c.Divisions evenly spaced along every branch, plus c.Merges merges, like GetEstimateMidpointAgain
+func (d *DAG) sample(c ParamConfig) (map[string]bool, error) {
+	if c.Strategy == StrategyExact || (c.Strategy != StrategyEstimate && d.GetOrder() <= c.Limit) {
+		return d.GetVertices(), nil
+	}
+
+	tovisit := d.GetNMerges(c.Merges)
+	for leaf := range d.GetLeafs() {
+		tovisit[leaf] = true
+		ancestors, err := d.GetOrderedAncestors(leaf)
+		if err != nil {
+			return nil, err
+		}
+		increment := int(math.Max(1, float64(len(ancestors)/(c.Divisions+1))))
+		for i := increment; i < len(ancestors); i += increment {
+			tovisit[ancestors[i]] = true
+		}
+	}
+	return tovisit, nil
+}
+
+// GetMidPoints picks up to k commits to ask about at once, whose answers together split the candidates as finely as possible
+// pending are questions that have already been asked but not answered yet, which the new ones work around.
+// The first question (when nothing is pending) is GetMidPoint, and each one after that greedily adds the most information
+// (per unit of cost, with costs) to the answers before it. Big DAGs only have a sample of their commits looked at.
+// Fewer than k are returned once no other question would tell us anything, and none if nothing can be tested.
+func (d *DAG) GetMidPoints(ctx context.Context, c ParamConfig, k int, pending []string) ([]string, error) {
+	var questions []string
+	asked := make(map[string]bool)
+	for _, q := range pending {
+		asked[q] = true
+	}
+
+	if len(pending) == 0 && k > 0 {
+		midpoint, err := d.GetMidPoint(ctx, c)
+		if err != nil || midpoint == "" {
+			return nil, err
+		}
+		questions = append(questions, midpoint)
+		asked[midpoint] = true
+	}
+
+	sample, err := d.sample(c)
+	if err != nil {
+		return nil, err
+	}
+
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+
+	p := d.newPartition()
+	for q := range asked {
+		// Pending questions may have been ruled out by the answers since
+		if d.vertices[q] {
+			d.refine(p, q)
+		}
+	}
+
+	for len(questions) < k {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var best string
+		bestValue := 0.0
+		for v := range sample {
+			if asked[v] || !d.vertices[v] || !d.eligible(v) {
+				continue
+			}
+
+			cost := d.costs.of(v)
+			if math.IsInf(cost, 1) {
+				continue
+			}
+
+			bad, _ := d.split(p, v)
+			if value := p.gain(bad) / cost; value > bestValue {
+				best, bestValue = v, value
+			}
+		}
+		if best == "" {
+			break
+		}
+
+		questions = append(questions, best)
+		asked[best] = true
+		d.refine(p, best)
+	}
+
+	return questions, nil
+}
@@ -0,0 +1,101 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// positions turns the questions on a chain back into numbers, in order
+func positions(t *testing.T, questions []string) []int {
+	var ps []int
+	seen := make(map[string]bool)
+	for _, q := range questions {
+		if seen[q] {
+			t.Fatalf("%v was picked twice in %v", q, questions)
+		}
+		seen[q] = true
+		var p int
+		fmt.Sscan(q, &p)
+		ps = append(ps, p)
+	}
+	sort.Ints(ps)
+	return ps
+}
+
+func near(got []int, want ...int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] < want[i]-2 || got[i] > want[i]+2 {
+			return false
+		}
+	}
+	return true
+}
+
+// On a chain the best k questions split it into k+1 equal parts
+func TestGetMidPointsChain(t *testing.T) {
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	ctx := context.Background()
+	d := chainDAG(t, 100)
+
+	questions, err := d.GetMidPoints(ctx, exact, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, questions); !near(got, 24, 49, 74) {
+		t.Errorf("got %v, want about 24, 49 and 74", got)
+	}
+
+	// The questions in flight are worked around
+	questions, err = d.GetMidPoints(ctx, exact, 2, []string{"49"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, questions); !near(got, 24, 74) {
+		t.Errorf("got %v with 49 pending, want about 24 and 74", got)
+	}
+
+	// The last commit would be bad whatever the culprit, so once the others are asked there is nothing more to tell
+	small := chainDAG(t, 4)
+	questions, err = small.GetMidPoints(ctx, exact, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, questions); !near(got, 0, 1, 2) {
+		t.Errorf("got %v on a chain of 4", questions)
+	}
+}
+
+func TestGetMidPointsUntestable(t *testing.T) {
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	ctx := context.Background()
+	d := chainDAG(t, 20)
+
+	costs := Costs{}
+	for v := range d.GetVertices() {
+		if v != "3" && v != "7" {
+			costs[v] = Untestable
+		}
+	}
+	d.SetCosts(costs)
+	questions, err := d.GetMidPoints(ctx, exact, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(t, questions); len(got) != 2 || got[0] != 3 || got[1] != 7 {
+		t.Errorf("got %v, want only 3 and 7", got)
+	}
+
+	costs = Costs{}
+	for v := range d.GetVertices() {
+		costs[v] = Untestable
+	}
+	d.SetCosts(costs)
+	if questions, err := d.GetMidPoints(ctx, exact, 5, nil); err != nil || len(questions) != 0 {
+		t.Errorf("got %v (%v) with nothing testable", questions, err)
+	}
+}
@@ -0,0 +1,131 @@
+package dag
+
+import (
+	"math"
+	"math/bits"
+)
+
+// ExactLimit is the most candidates GetBound will work out the true optimal worst case for by default
+// Above this it is far too slow, and only the information-theoretic bound is given.
+const ExactLimit = 20
+
+// Bound is how few questions could possibly be needed to find the culprit among the current candidates
+type Bound struct {
+	// Candidates is the number of commits that could still be the culprit (the eligible ones in the DAG and the most recent bad)
+	Candidates int
+	// Lower is the information-theoretic lower bound, ceil(log2(Candidates))
+	Lower int
+	// Optimal is the true optimal worst case, asking only about candidates, which is only set if Exact is
+	Optimal int
+	Exact   bool
+}
+
+// Best is the tightest bound we have
+func (b Bound) Best() int {
+	if b.Exact {
+		return b.Optimal
+	}
+	return b.Lower
+}
+
+// LowerBound is the information-theoretic lower bound for the number of candidates,
+// as each answer can at best halve them
+func LowerBound(candidates int) int {
+	if candidates <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log2(float64(candidates))))
+}
+
+// GetBound works out the bounds for the DAG as it is now, including the true optimal worst case
+// if there are at most exactLimit candidates. With SetOnly only the eligible commits are candidates.
+func (d *DAG) GetBound(exactLimit int) Bound {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+
+	candidates := 0
+	for v := range d.vertices {
+		if d.eligible(v) {
+			candidates++
+		}
+	}
+	if d.MostRecentBad != "" && !d.vertices[d.MostRecentBad] {
+		candidates++
+	}
+
+	b := Bound{
+		Candidates: candidates,
+		Lower:      LowerBound(candidates),
+	}
+
+	if candidates <= exactLimit && candidates <= 32 {
+		b.Optimal = d.optimal()
+		b.Exact = true
+	}
+
+	return b
+}
+
+// optimal does a minimax search over every possible question, with the candidates as a bitmask
+// Asking about x splits the candidates into those that are x or its ancestors (if x is bad) and the rest (if x is good).
+// Only eligible commits are candidates or get asked about. The caller must hold the read lock.
+func (d *DAG) optimal() int {
+	var commits []string
+	index := make(map[string]uint)
+	for v := range d.vertices {
+		if !d.eligible(v) {
+			continue
+		}
+		index[v] = uint(len(commits))
+		commits = append(commits, v)
+	}
+
+	// ancestors[i] is the mask of candidates that are commit i or its ancestors
+	ancestors := make([]uint32, len(commits))
+	for i, v := range commits {
+		ancestors[i] = 1 << uint(i)
+		d.visitAncestors(v, func(ancestor string) {
+			if j, ok := index[ancestor]; ok {
+				ancestors[i] |= 1 << j
+			}
+		})
+	}
+
+	all := uint32(1)<<uint(len(commits)) - 1
+	if d.MostRecentBad != "" && !d.vertices[d.MostRecentBad] {
+		// The most recent bad commit is a descendant of everything left, and asking about it tells us nothing
+		all |= 1 << uint(len(commits))
+	}
+
+	memo := make(map[uint32]int)
+	var search func(candidates uint32) int
+	search = func(candidates uint32) int {
+		if bits.OnesCount32(candidates) <= 1 {
+			return 0
+		}
+		if best, ok := memo[candidates]; ok {
+			return best
+		}
+
+		best := math.MaxInt32
+		for i := range commits {
+			bad := candidates & ancestors[i]
+			good := candidates &^ ancestors[i]
+			if bad == 0 || good == 0 {
+				continue
+			}
+			worst := search(bad)
+			if other := search(good); other > worst {
+				worst = other
+			}
+			if 1+worst < best {
+				best = 1 + worst
+			}
+		}
+
+		memo[candidates] = best
+		return best
+	}
+
+	return search(all)
+}
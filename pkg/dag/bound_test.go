@@ -0,0 +1,66 @@
+package dag
+
+import (
+	"fmt"
+	"testing"
+)
+
+// chain is c0 <- c1 <- ... <- c(n-1)
+func chain(n int) map[string][]string {
+	parents := map[string][]string{}
+	for i := 1; i < n; i++ {
+		parents[fmt.Sprint("c", i)] = []string{fmt.Sprint("c", i-1)}
+	}
+	return parents
+}
+
+func TestGetBound(t *testing.T) {
+	diamond := map[string][]string{"b": {"a"}, "c": {"a"}, "d": {"b", "c"}}
+	// Every question but the merge only rules out one of the roots, which is far from halving them
+	merges := map[string][]string{"m": {"a", "b", "c"}}
+
+	tests := []struct {
+		name          string
+		parents       map[string][]string
+		mostRecentBad string
+		only          map[string]bool
+		exactLimit    int
+		want          Bound
+	}{
+		{"chain", chain(4), "", nil, ExactLimit, Bound{Candidates: 4, Lower: 2, Optimal: 2, Exact: true}},
+		{"chain with bad", chain(4), "bad", nil, ExactLimit, Bound{Candidates: 5, Lower: 3, Optimal: 3, Exact: true}},
+		{"chain bad in the dag", chain(4), "c3", nil, ExactLimit, Bound{Candidates: 4, Lower: 2, Optimal: 2, Exact: true}},
+		{"diamond", diamond, "", nil, ExactLimit, Bound{Candidates: 4, Lower: 2, Optimal: 2, Exact: true}},
+		{"diamond with bad", diamond, "bad", nil, ExactLimit, Bound{Candidates: 5, Lower: 3, Optimal: 3, Exact: true}},
+		{"merges", merges, "", nil, ExactLimit, Bound{Candidates: 4, Lower: 2, Optimal: 3, Exact: true}},
+		{"merges with bad", merges, "bad", nil, ExactLimit, Bound{Candidates: 5, Lower: 3, Optimal: 4, Exact: true}},
+		{"at the limit", chain(ExactLimit), "", nil, ExactLimit, Bound{Candidates: ExactLimit, Lower: 5, Optimal: 5, Exact: true}},
+		{"over the limit", chain(ExactLimit), "bad", nil, ExactLimit, Bound{Candidates: ExactLimit + 1, Lower: 5}},
+		{"no exact", chain(4), "", nil, 0, Bound{Candidates: 4, Lower: 2}},
+		{"only", chain(8), "", map[string]bool{"c1": true, "c4": true, "c6": true}, ExactLimit, Bound{Candidates: 3, Lower: 2, Optimal: 2, Exact: true}},
+		{"only the merge and a root", merges, "bad", map[string]bool{"a": true, "m": true}, ExactLimit, Bound{Candidates: 3, Lower: 2, Optimal: 2, Exact: true}},
+	}
+	for _, tt := range tests {
+		d := newDAG(t, tt.parents)
+		d.MostRecentBad = tt.mostRecentBad
+		d.SetOnly(tt.only)
+
+		got := d.GetBound(tt.exactLimit)
+		if got != tt.want {
+			t.Errorf("%v: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		if got.Best() < got.Lower {
+			t.Errorf("%v: best %v is below the lower bound %v", tt.name, got.Best(), got.Lower)
+		}
+	}
+}
+
+// A chain can always be halved, so the optimal worst case is just the lower bound
+func TestOptimalChain(t *testing.T) {
+	for n := 2; n <= 12; n++ {
+		bound := newDAG(t, chain(n)).GetBound(ExactLimit)
+		if !bound.Exact || bound.Optimal != LowerBound(n) {
+			t.Errorf("chain of %v: got %+v, want an optimal of %v", n, bound, LowerBound(n))
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status is whether a commit could still be the culprit, or which way it was ruled out
+type Status int
+
+// The statuses of a commit in Candidates
+const (
+	// Candidate could still be the culprit
+	Candidate Status = iota
+	// RuledGood is a good commit, or an ancestor of one
+	RuledGood
+	// RuledBad is not an ancestor of the most recent bad commit, or is a bad commit which has since been beaten by one of its ancestors
+	RuledBad
+	// Excluded hasn't been ruled out, but the bisection is limited to other commits, see SetOnly
+	Excluded
+)
+
+func (s Status) String() string {
+	switch s {
+	case Candidate:
+		return "candidate"
+	case RuledGood:
+		return "ruled good"
+	case RuledBad:
+		return "ruled bad"
+	case Excluded:
+		return "excluded"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Answer is a single answer about a commit
+type Answer struct {
+	Commit string `json:"commit"`
+	Good   bool   `json:"good"`
+}
+
+func (a Answer) String() string {
+	if a.Good {
+		return "good " + a.Commit
+	}
+	return "bad " + a.Commit
+}
+
+// ErrNothingToUndo is returned by Undo when there are no answers left
+var ErrNothingToUndo = errors.New("there are no answers to undo")
+
+// Candidates is a view over a base DAG that is never changed, of which commits could still be the culprit.
+// Every commit that has been ruled out remembers which answer did it, so answers can be undone and
+// we can say why a commit is no longer a candidate.
+// The candidates are the commits left in View, plus the most recent bad commit.
+type Candidates struct {
+	base *DAG
+	// view is a clone of the base with the ruled out commits deleted, which is what GetMidPoint works on
+	view    *DAG
+	answers []Answer
+	// ruled is how each commit was ruled out, and ruledBy the index of the answer that did it
+	ruled   map[string]Status
+	ruledBy map[string]int
+	weights Weights
+	costs   Costs
+	only    map[string]bool
+}
+
+// NewCandidates starts with every commit of the base as a candidate
+// The base must not be changed afterwards, it is only ever cloned.
+func NewCandidates(base *DAG) *Candidates {
+	return &Candidates{
+		base:    base,
+		view:    base.Clone(),
+		ruled:   make(map[string]Status),
+		ruledBy: make(map[string]int),
+	}
+}
+
+// SetWeights makes the view's midpoint selection split the weight of the candidates, see DAG.SetWeights
+// Only the view gets them, the base is left alone.
+func (c *Candidates) SetWeights(w Weights) {
+	c.weights = w
+	c.view.SetWeights(w)
+}
+
+// SetCosts makes the view's midpoint selection go for the most information per unit of cost, see DAG.SetCosts
+func (c *Candidates) SetCosts(costs Costs) {
+	c.costs = costs
+	c.view.SetCosts(costs)
+}
+
+// SetOnly limits the bisection to the given commits, see DAG.SetOnly
+// The answers still rule out commits through the whole graph, so the view keeps every commit that hasn't been ruled out.
+func (c *Candidates) SetOnly(only map[string]bool) {
+	c.only = only
+	c.view.SetOnly(only)
+}
+
+// View is the DAG of the commits that are still candidates (apart from the most recent bad one)
+// It is only for reading, answers should go through Good and Bad.
+func (c *Candidates) View() *DAG {
+	return c.view
+}
+
+// MostRecentBad is the latest bad commit, which is the culprit once nothing else is left
+func (c *Candidates) MostRecentBad() string {
+	return c.view.MostRecentBad
+}
+
+// Len is the number of commits that could still be the culprit
+func (c *Candidates) Len() int {
+	n := c.view.GetEligibleOrder()
+	if c.view.MostRecentBad != "" {
+		n++
+	}
+	return n
+}
+
+// Answers is every answer so far, oldest first
+func (c *Candidates) Answers() []Answer {
+	return append([]Answer(nil), c.answers...)
+}
+
+// Good rules out the commit and all of its ancestors, like DAG.GoodCommit
+func (c *Candidates) Good(commit string) error {
+	ancestors, err := c.view.GetOrderedAncestors(commit)
+	if err != nil {
+		return err
+	}
+
+	answer := len(c.answers)
+	for _, v := range append(ancestors, commit) {
+		c.rule(v, RuledGood, answer)
+	}
+
+	c.answers = append(c.answers, Answer{Commit: commit, Good: true})
+	return nil
+}
+
+// Bad rules out everything that isn't an ancestor of the commit, like DAG.BadCommit
+// The commit becomes the most recent bad commit, and the previous one is ruled out.
+func (c *Candidates) Bad(commit string) error {
+	ancestors, err := c.view.GetOrderedAncestors(commit)
+	if err != nil {
+		return err
+	}
+
+	answer := len(c.answers)
+	keep := make(map[string]bool, len(ancestors))
+	for _, v := range ancestors {
+		keep[v] = true
+	}
+	for v := range c.view.GetVertices() {
+		if !keep[v] && v != commit {
+			c.rule(v, RuledBad, answer)
+		}
+	}
+	c.view.DeleteVertex(commit)
+
+	if previous := c.view.MostRecentBad; previous != "" {
+		c.ruled[previous] = RuledBad
+		c.ruledBy[previous] = answer
+	}
+	c.view.MostRecentBad = commit
+
+	c.answers = append(c.answers, Answer{Commit: commit, Good: false})
+	return nil
+}
+
+// Answer applies the answer with Good or Bad
+func (c *Candidates) Answer(a Answer) error {
+	if a.Good {
+		return c.Good(a.Commit)
+	}
+	return c.Bad(a.Commit)
+}
+
+// rule takes the commit out of the view, remembering why
+func (c *Candidates) rule(v string, s Status, answer int) {
+	c.view.DeleteVertex(v)
+	c.ruled[v] = s
+	c.ruledBy[v] = answer
+}
+
+// Undo takes back the last answer, making everything it ruled out a candidate again
+func (c *Candidates) Undo() (Answer, error) {
+	if len(c.answers) == 0 {
+		return Answer{}, ErrNothingToUndo
+	}
+
+	last := len(c.answers) - 1
+	undone := c.answers[last]
+	c.answers = c.answers[:last]
+
+	for v, answer := range c.ruledBy {
+		if answer == last {
+			delete(c.ruled, v)
+			delete(c.ruledBy, v)
+		}
+	}
+
+	// Deleting is all the view can do, so start again from the base
+	c.view = c.base.Clone()
+	if c.weights != nil {
+		c.view.SetWeights(c.weights)
+	}
+	if c.costs != nil {
+		c.view.SetCosts(c.costs)
+	}
+	if c.only != nil {
+		c.view.SetOnly(c.only)
+	}
+	for v := range c.ruled {
+		c.view.DeleteVertex(v)
+	}
+	for _, a := range c.answers {
+		if !a.Good {
+			c.view.DeleteVertex(a.Commit)
+			c.view.MostRecentBad = a.Commit
+		}
+	}
+
+	return undone, nil
+}
+
+// Status is whether the commit could still be the culprit
+func (c *Candidates) Status(commit string) (Status, error) {
+	if err := c.known(commit); err != nil {
+		return Candidate, err
+	}
+	if status, ruled := c.ruled[commit]; ruled {
+		return status, nil
+	}
+	if c.only != nil && !c.only[commit] && commit != c.view.MostRecentBad {
+		return Excluded, nil
+	}
+	return Candidate, nil
+}
+
+// Why returns the answer that ruled the commit out, or false if it is still a candidate
+func (c *Candidates) Why(commit string) (Answer, bool, error) {
+	if err := c.known(commit); err != nil {
+		return Answer{}, false, err
+	}
+	answer, ruled := c.ruledBy[commit]
+	if !ruled {
+		return Answer{}, false, nil
+	}
+	return c.answers[answer], true, nil
+}
+
+// Explain says in words why the commit is or isn't still a candidate
+func (c *Candidates) Explain(commit string) (string, error) {
+	answer, ruled, err := c.Why(commit)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !ruled && commit == c.view.MostRecentBad:
+		return fmt.Sprintf("%s is the most recent bad commit, so it is the culprit unless one of its ancestors is bad", commit), nil
+	case !ruled && c.only != nil && !c.only[commit]:
+		return fmt.Sprintf("%s is left out of the bisection, so it is never asked about and can't be the culprit", commit), nil
+	case !ruled:
+		return fmt.Sprintf("%s is still a candidate", commit), nil
+	case c.ruled[commit] == RuledGood && answer.Commit == commit:
+		return fmt.Sprintf("%s was answered good", commit), nil
+	case c.ruled[commit] == RuledGood:
+		return fmt.Sprintf("%s is an ancestor of %s, which was answered good", commit, answer.Commit), nil
+	case commit == c.lastBadBefore(c.ruledBy[commit]):
+		return fmt.Sprintf("%s was answered bad, but so was its ancestor %s", commit, answer.Commit), nil
+	default:
+		return fmt.Sprintf("%s is not an ancestor of %s, which was answered bad", commit, answer.Commit), nil
+	}
+}
+
+// lastBadBefore is the most recent bad commit just before the answer
+func (c *Candidates) lastBadBefore(answer int) string {
+	for i := answer - 1; i >= 0; i-- {
+		if !c.answers[i].Good {
+			return c.answers[i].Commit
+		}
+	}
+	return c.base.MostRecentBad
+}
+
+func (c *Candidates) known(commit string) error {
+	c.base.muDAG.RLock()
+	defer c.base.muDAG.RUnlock()
+	return c.base.saneVertex(commit)
+}
@@ -0,0 +1,150 @@
+package dag
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCandidatesWhy(t *testing.T) {
+	base := newDAG(t, header)
+	c := NewCandidates(base)
+	for _, s := range []step{good("B"), bad("E"), bad("C")} {
+		err := c.Answer(Answer{Commit: s.commit, Good: s.good})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		commit string
+		status Status
+		by     string
+		says   string
+	}{
+		{"A", RuledGood, "good B", "ancestor of B"},
+		{"B", RuledGood, "good B", "answered good"},
+		{"D", RuledBad, "bad E", "not an ancestor of E"},
+		{"F", RuledBad, "bad E", "not an ancestor of E"},
+		{"G", RuledBad, "bad E", "not an ancestor of E"},
+		{"E", RuledBad, "bad C", "so was its ancestor C"},
+		{"C", Candidate, "", "most recent bad commit"},
+	}
+	for _, tt := range tests {
+		status, err := c.Status(tt.commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != tt.status {
+			t.Errorf("%v is %v, want %v", tt.commit, status, tt.status)
+		}
+
+		answer, ruled, err := c.Why(tt.commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ruled != (tt.by != "") || (ruled && answer.String() != tt.by) {
+			t.Errorf("%v was ruled out by %v (%v), want %q", tt.commit, answer, ruled, tt.by)
+		}
+
+		says, err := c.Explain(tt.commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(says, tt.says) {
+			t.Errorf("%v: got %q, want %q", tt.commit, says, tt.says)
+		}
+	}
+
+	if c.Len() != 1 || c.MostRecentBad() != "C" {
+		t.Errorf("got %v candidates ending at %v, want just C", c.Len(), c.MostRecentBad())
+	}
+	if base.GetOrder() != len(sortedVertices(newDAG(t, header))) {
+		t.Errorf("the base was changed, it has %v commits", base.GetOrder())
+	}
+	if _, err := c.Status("Z"); err == nil {
+		t.Error("no error for an unknown commit")
+	}
+}
+
+func TestCandidatesUndo(t *testing.T) {
+	c := NewCandidates(newDAG(t, header))
+	if _, err := c.Undo(); err != ErrNothingToUndo {
+		t.Errorf("got %v undoing nothing, want ErrNothingToUndo", err)
+	}
+
+	c.Good("B")
+	c.Bad("E")
+	c.Bad("C")
+
+	undone, err := c.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone != (Answer{Commit: "C"}) {
+		t.Errorf("undid %v, want bad C", undone)
+	}
+	if got, want := sortedVertices(c.View()), []string{"C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after undoing, want %v", got, want)
+	}
+	if s, _ := c.Status("E"); s != Candidate || c.MostRecentBad() != "E" {
+		t.Errorf("E is %v and the most recent bad is %v, want E back as the most recent bad", s, c.MostRecentBad())
+	}
+
+	c.Undo()
+	c.Undo()
+	if c.Len() != len(header)+1 || c.MostRecentBad() != "" || len(c.Answers()) != 0 {
+		t.Errorf("got %v candidates and %v answers after undoing everything", c.Len(), c.Answers())
+	}
+}
+
+// The candidates should always be what pruning the DAG would leave, however many answers are undone
+func TestCandidatesMatchPruning(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		parents := randomHistory(r, 2+r.Intn(40))
+		c := NewCandidates(newDAG(t, parents))
+
+		// pruned[i] is the DAG after the first i answers, done the destructive way
+		pruned := []*DAG{newDAG(t, parents)}
+		for c.View().GetOrder() > 0 {
+			vertices := sortedVertices(c.View())
+			a := Answer{Commit: vertices[r.Intn(len(vertices))], Good: r.Intn(2) == 0}
+
+			d := newDAG(t, parents)
+			for _, previous := range append(c.Answers(), a) {
+				if previous.Good {
+					d.GoodCommit(previous.Commit)
+				} else {
+					d.BadCommit(previous.Commit)
+				}
+			}
+			pruned = append(pruned, d)
+
+			err := c.Answer(a)
+			if err != nil {
+				t.Fatalf("seed %v: %v", seed, err)
+			}
+
+			// Now and then go back a few answers, and carry on from there
+			if r.Intn(4) == 0 {
+				for n := r.Intn(len(pruned)); n > 0; n-- {
+					c.Undo()
+					pruned = pruned[:len(pruned)-1]
+				}
+			}
+
+			want := pruned[len(pruned)-1]
+			if got := sortedVertices(c.View()); !reflect.DeepEqual(got, sortedVertices(want)) || c.MostRecentBad() != want.MostRecentBad {
+				t.Fatalf("seed %v after %v: got %v ending at %v, want %v ending at %v", seed, c.Answers(), got, c.MostRecentBad(), sortedVertices(want), want.MostRecentBad)
+			}
+			for v := range parents {
+				s, _ := c.Status(v)
+				if alive := want.GetVertices()[v] || v == want.MostRecentBad; alive != (s == Candidate) {
+					t.Fatalf("seed %v after %v: %v is %v", seed, c.Answers(), v, s)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+// Untestable is the cost of a commit that can't be tested at all, which is never asked about
+var Untestable = math.Inf(1)
+
+// Costs are how expensive each commit is to test, such as a full rebuild after a dependency bump
+// Any commit that isn't given costs 1.
+type Costs map[string]float64
+
+// LoadCosts reads costs from a json file, {"<sha>": 10, "<sha>": "untestable", ...}
+func LoadCosts(path string) (Costs, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	costs := make(Costs, len(raw))
+	for commit, value := range raw {
+		switch cost := value.(type) {
+		case float64:
+			if cost <= 0 {
+				return nil, fmt.Errorf("%s: cost of %s must be positive, got %v", path, commit, cost)
+			}
+			costs[commit] = cost
+		case string:
+			if cost != "untestable" {
+				return nil, fmt.Errorf("%s: cost of %s must be a number or \"untestable\", got %q", path, commit, cost)
+			}
+			costs[commit] = Untestable
+		default:
+			return nil, fmt.Errorf("%s: cost of %s must be a number or \"untestable\", got %v", path, commit, value)
+		}
+	}
+	return costs, nil
+}
+
+// of is the cost of testing the commit
+func (c Costs) of(v string) float64 {
+	if cost, ok := c[v]; ok {
+		return cost
+	}
+	return 1
+}
+
+// SetCosts makes the midpoint selection go for the most information per unit of cost, rather than the best split
+// nil goes back to the best split. The costs are shared with clones, so they must not be changed afterwards.
+func (d *DAG) SetCosts(c Costs) {
+	d.muDAG.Lock()
+	defer d.muDAG.Unlock()
+	d.costs = c
+}
+
+// GetCosts returns the costs given to SetCosts, or nil
+func (d *DAG) GetCosts() Costs {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	return d.costs
+}
+
+// GetCost is the cost of testing the commit, 1 without costs
+func (d *DAG) GetCost(v string) float64 {
+	return d.GetCosts().of(v)
+}
+
+// score is how good a question the commit is, out of the total (weight of the) candidates, where higher is better.
+// Without costs it is the smaller side of the split. With costs it is the information the answer gives
+// (the binary entropy of the split) per unit of cost, which greedily keeps the expected total cost down.
+// The entropy only goes up with the smaller side, so uniform costs pick the same commit as no costs at all.
+// Untestable commits score -1, so they are never picked.
+func score(result CommitAncestors, total float64, costs Costs) float64 {
+	if costs == nil {
+		return math.Min(result.Value, total-result.Value)
+	}
+
+	cost := costs.of(result.Commit)
+	if math.IsInf(cost, 1) {
+		return -1
+	}
+
+	p := result.Value / total
+	if p <= 0 || p >= 1 {
+		return 0
+	}
+	entropy := -p*math.Log2(p) - (1-p)*math.Log2(1-p)
+	return entropy / cost
+}
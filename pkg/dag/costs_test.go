@@ -0,0 +1,154 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Uniform costs should split exactly as well as no costs at all
+func TestUniformCostsSplitTheSame(t *testing.T) {
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	ctx := context.Background()
+
+	for seed := int64(0); seed < 50; seed++ {
+		parents := randomHistory(rand.New(rand.NewSource(seed)), 60)
+		d := newDAG(t, parents)
+		if d.GetOrder() < 2 {
+			continue
+		}
+		costed := d.Clone()
+		costed.SetCosts(Costs{})
+
+		split := func(q string) float64 {
+			bad, err := d.GetBadMass(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return math.Min(bad, d.GetMass()-bad)
+		}
+
+		want, err := d.GetMidPoint(ctx, exact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := costed.GetMidPoint(ctx, exact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Ties can go either way, but the split has to be as good
+		if split(got) != split(want) {
+			t.Errorf("seed %v: got %v splitting %v, want %v splitting %v", seed, got, split(got), want, split(want))
+		}
+	}
+}
+
+func TestUntestableIsNeverAsked(t *testing.T) {
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	ctx := context.Background()
+	d := chainDAG(t, 10)
+
+	d.SetCosts(Costs{"5": Untestable})
+	q, err := d.GetMidPoint(ctx, exact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "4" && q != "6" {
+		t.Errorf("got %v with 5 untestable, want 4 or 6", q)
+	}
+
+	everything := Costs{}
+	for v := range d.GetVertices() {
+		everything[v] = Untestable
+	}
+	d.SetCosts(everything)
+	if q, err := d.GetMidPoint(ctx, exact); err != nil || q != "" {
+		t.Errorf("got %v (%v) with nothing testable", q, err)
+	}
+	if q, err := d.GetMidPointSkipping(ctx, exact, map[string]bool{"1": true}); err != nil || q != "" {
+		t.Errorf("got %v (%v) skipping with nothing testable", q, err)
+	}
+}
+
+// The second half of the chain is expensive to test, which should be avoided where it can be
+func TestCostsKeepTheTotalDown(t *testing.T) {
+	const n = 200
+	costs := Costs{}
+	for i := n / 2; i < n; i++ {
+		costs[fmt.Sprint(i)] = 20
+	}
+
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	spent := func(c Costs, culprit int) float64 {
+		d := chainDAG(t, n)
+		d.BadCommit(fmt.Sprint(n - 1))
+		d.GoodCommit("0")
+		d.SetCosts(c)
+
+		total := 0.0
+		for d.GetOrder() > 0 {
+			q, err := d.GetMidPoint(context.Background(), exact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			total += costs.of(q)
+			var i int
+			fmt.Sscan(q, &i)
+			if i >= culprit {
+				d.BadCommit(q)
+			} else {
+				d.GoodCommit(q)
+			}
+		}
+		return total
+	}
+
+	aware, unaware := 0.0, 0.0
+	for culprit := 1; culprit < n; culprit += 3 {
+		aware += spent(costs, culprit)
+		unaware += spent(nil, culprit)
+	}
+	if aware >= unaware {
+		t.Errorf("spent %v knowing the costs and %v without", aware, unaware)
+	}
+}
+
+func TestLoadCosts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "costs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{`{"a": 2.5, "b": "untestable"}`, ""},
+		{`{"a": 0}`, "must be positive"},
+		{`{"a": "expensive"}`, "must be a number or \"untestable\""},
+		{`{"a": null}`, "must be a number"},
+		{`[]`, "cannot unmarshal"},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%v.json", i))
+		if err := ioutil.WriteFile(path, []byte(tt.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := LoadCosts(path)
+		if tt.want == "" {
+			if err != nil || c.of("a") != 2.5 || c.of("b") != Untestable || c.of("c") != 1 {
+				t.Errorf("%v: got %v (%v)", tt.file, c, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got error %v, want %q", tt.file, err, tt.want)
+		}
+	}
+}
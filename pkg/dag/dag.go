@@ -2,11 +2,13 @@
 package dag
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
-	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 /*
@@ -57,10 +59,8 @@ D   E   F
  \ /
   G
 
-THEN AFTER BAD:
+THEN AFTER BAD (E goes too, but is kept as MostRecentBad, so the candidates are C and E):
       C
-       \
-        F
 
 */
 
@@ -72,7 +72,17 @@ type DAG struct {
 	vertices      map[string]bool
 	inboundEdge   map[string]map[string]bool
 	outboundEdge  map[string]map[string]bool
+	pool          *Pool
+	weights       Weights
+	costs         Costs
+	only          map[string]bool
 	MostRecentBad string
+
+	// cow is set once the DAG has been cloned, after which the edge maps may be shared with clones,
+	// and have to be copied before they are changed unless they are in ownIn / ownOut
+	cow    bool
+	ownIn  map[string]bool
+	ownOut map[string]bool
 }
 
 // ParamConfig is simply the configuration for the Midpoint selection
@@ -80,12 +90,27 @@ type DAG struct {
 // Your mission, should you choose to select it, is to modify these values to get as close as possible to the "ideal" score
 type ParamConfig struct {
 	// Limit is the limit, below which the very intensive "proper" midpoint selection will happen
-	Limit int
+	Limit int `json:"limit"`
 	// Divisions is the number of samples to take in the "lightweight" midpoint selection
-	Divisions int
+	Divisions int `json:"divisions"`
 	// Merges is the number of merges to take in the "lighweight" midpoint selection
-	Merges int
-}
+	Merges int `json:"merges"`
+	// Strategy picks which midpoint selection to use, see Strategies
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// The midpoint selection strategies
+const (
+	// StrategyAuto uses the "proper" midpoint below Limit, and the estimate above it
+	StrategyAuto = "auto"
+	// StrategyExact always uses the "proper" midpoint, however big the DAG is
+	StrategyExact = "exact"
+	// StrategyEstimate always uses the "lightweight" estimate
+	StrategyEstimate = "estimate"
+)
+
+// Strategies lists the valid values for ParamConfig.Strategy (empty means StrategyAuto)
+var Strategies = []string{StrategyAuto, StrategyExact, StrategyEstimate}
 
 // NewDAG creates / initializes a new DAG.
 func NewDAG() *DAG {
@@ -96,6 +121,91 @@ func NewDAG() *DAG {
 	}
 }
 
+// Clone makes a copy of the DAG that can be pruned without touching the original (or the other way round)
+// It is cheap, as the parents and children of every commit are shared until one side changes them.
+// This is how each instance of a repo gets the unpruned DAG, without building it again.
+func (d *DAG) Clone() *DAG {
+	d.muDAG.Lock()
+	defer d.muDAG.Unlock()
+
+	c := &DAG{
+		vertices:      copyMap(d.vertices),
+		inboundEdge:   make(map[string]map[string]bool, len(d.inboundEdge)),
+		outboundEdge:  make(map[string]map[string]bool, len(d.outboundEdge)),
+		pool:          d.pool,
+		weights:       d.weights,
+		costs:         d.costs,
+		only:          d.only,
+		MostRecentBad: d.MostRecentBad,
+	}
+	for v, parents := range d.inboundEdge {
+		c.inboundEdge[v] = parents
+	}
+	for v, children := range d.outboundEdge {
+		c.outboundEdge[v] = children
+	}
+
+	// Neither side owns any of the edge maps any more
+	d.share()
+	c.share()
+
+	return c
+}
+
+// share marks all the edge maps as shared with a clone
+func (d *DAG) share() {
+	d.cow = true
+	d.ownIn = make(map[string]bool)
+	d.ownOut = make(map[string]bool)
+}
+
+// parents returns the parents of v ready to be changed, copying them first if they are shared with a clone
+func (d *DAG) parents(v string) map[string]bool {
+	parents, exists := d.inboundEdge[v]
+	if !exists {
+		parents = make(map[string]bool)
+	} else if d.cow && !d.ownIn[v] {
+		parents = copyMap(parents)
+	} else {
+		return parents
+	}
+	d.inboundEdge[v] = parents
+	if d.cow {
+		d.ownIn[v] = true
+	}
+	return parents
+}
+
+// children returns the children of v ready to be changed, copying them first if they are shared with a clone
+func (d *DAG) children(v string) map[string]bool {
+	children, exists := d.outboundEdge[v]
+	if !exists {
+		children = make(map[string]bool)
+	} else if d.cow && !d.ownOut[v] {
+		children = copyMap(children)
+	} else {
+		return children
+	}
+	d.outboundEdge[v] = children
+	if d.cow {
+		d.ownOut[v] = true
+	}
+	return children
+}
+
+// AddVertex adds the vertex v, if it isn't already known, for a commit that may not have any edges.
+// AddVertex returns an error, if v is empty.
+func (d *DAG) AddVertex(v string) error {
+	d.muDAG.Lock()
+	defer d.muDAG.Unlock()
+
+	if v == "" {
+		return IdEmptyError{}
+	}
+	d.addVertex(v)
+	return nil
+}
+
 func (d *DAG) addVertex(v string) {
 	d.vertices[v] = true
 }
@@ -115,20 +225,24 @@ func (d *DAG) DeleteVertex(v string) error {
 	// delete v in outbound edges of parents
 	if _, exists := d.inboundEdge[v]; exists {
 		for parent := range d.inboundEdge[v] {
-			delete(d.outboundEdge[parent], v)
+			delete(d.children(parent), v)
 		}
 	}
 
 	// delete v in inbound edges of children
 	if _, exists := d.outboundEdge[v]; exists {
 		for child := range d.outboundEdge[v] {
-			delete(d.inboundEdge[child], v)
+			delete(d.parents(child), v)
 		}
 	}
 
 	// delete in- and outbound of v itself
 	delete(d.inboundEdge, v)
 	delete(d.outboundEdge, v)
+	if d.cow {
+		delete(d.ownIn, v)
+		delete(d.ownOut, v)
+	}
 
 	// delete v itself
 	delete(d.vertices, v)
@@ -172,21 +286,11 @@ func (d *DAG) AddEdge(src string, dst string) error {
 		return EdgeDuplicateError{src, dst}
 	}
 
-	// prepare d.outbound[src], iff needed
-	if _, exists := d.outboundEdge[src]; !exists {
-		d.outboundEdge[src] = make(map[string]bool)
-	}
-
-	// dst is a child of src
-	d.outboundEdge[src][dst] = true
-
-	// prepare d.inboundEdge[dst], iff needed
-	if _, exists := d.inboundEdge[dst]; !exists {
-		d.inboundEdge[dst] = make(map[string]bool)
-	}
+	// dst is a child of src (d.children prepares d.outboundEdge[src], iff needed)
+	d.children(src)[dst] = true
 
 	// src is a parent of dst
-	d.inboundEdge[dst][src] = true
+	d.parents(dst)[src] = true
 
 	return nil
 }
@@ -222,8 +326,8 @@ func (d *DAG) DeleteEdge(src string, dst string) error {
 	}
 
 	// delete inbound and outbound
-	delete(d.inboundEdge[dst], src)
-	delete(d.outboundEdge[src], dst)
+	delete(d.parents(dst), src)
+	delete(d.children(src), dst)
 
 	return nil
 }
@@ -275,6 +379,16 @@ func (d *DAG) GetVertices() map[string]bool {
 	return copyMap(d.vertices)
 }
 
+// GetParents returns the parents of the vertex v. GetParents returns an error, if v is nil or unknown.
+func (d *DAG) GetParents(v string) (map[string]bool, error) {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	if err := d.saneVertex(v); err != nil {
+		return nil, err
+	}
+	return copyMap(d.inboundEdge[v]), nil
+}
+
 // GetNMerges returns the first n vertices with multiple parents
 func (d *DAG) GetNMerges(n int) map[string]bool {
 	d.muDAG.RLock()
@@ -301,14 +415,13 @@ func (d *DAG) GetNMerges(n int) map[string]bool {
 func (d *DAG) GetOrderedAncestors(v string) ([]string, error) {
 	d.muDAG.RLock()
 	defer d.muDAG.RUnlock()
-	vertices, _, err := d.AncestorsWalker(v)
-	if err != nil {
+	if err := d.saneVertex(v); err != nil {
 		return nil, err
 	}
 	var ancestors []string
-	for v := range vertices {
-		ancestors = append(ancestors, v)
-	}
+	d.visitAncestors(v, func(ancestor string) {
+		ancestors = append(ancestors, ancestor)
+	})
 	return ancestors, nil
 }
 
@@ -316,17 +429,38 @@ func (d *DAG) GetOrderedAncestors(v string) ([]string, error) {
 func (d *DAG) GetAncestorsLength(v string) (int, error) {
 	d.muDAG.RLock()
 	defer d.muDAG.RUnlock()
-	vertices, _, err := d.AncestorsWalker(v)
-	if err != nil {
+	if err := d.saneVertex(v); err != nil {
 		return 0, err
 	}
 	ancestorslength := 0
-	for range vertices {
+	d.visitAncestors(v, func(string) {
 		ancestorslength++
-	}
+	})
 	return ancestorslength, nil
 }
 
+// visitAncestors calls visit on every ancestor of v in breadth first order, without
+// the goroutine and channel of AncestorsWalker. The caller must hold the read lock.
+func (d *DAG) visitAncestors(v string, visit func(string)) {
+	fifo := make([]string, 0, len(d.inboundEdge[v]))
+	visited := make(map[string]bool)
+	for parent := range d.inboundEdge[v] {
+		visited[parent] = true
+		fifo = append(fifo, parent)
+	}
+	for len(fifo) > 0 {
+		top := fifo[0]
+		fifo = fifo[1:]
+		for parent := range d.inboundEdge[top] {
+			if !visited[parent] {
+				visited[parent] = true
+				fifo = append(fifo, parent)
+			}
+		}
+		visit(top)
+	}
+}
+
 // AncestorsWalker returns a channel and subsequently returns / walks all
 // ancestors of the vertex v in a breath first order. The second channel
 // returned may be used to stop further walking. AncestorsWalker returns an
@@ -342,6 +476,7 @@ func (d *DAG) AncestorsWalker(v string) (chan string, chan bool, error) {
 	}
 	vertices := make(chan string)
 	signal := make(chan bool, 1)
+	atomic.AddInt64(&started, 1)
 	go func() {
 		d.muDAG.RLock()
 		d.walkAncestors(v, vertices, signal)
@@ -412,7 +547,7 @@ func (d *DAG) saneVertex(v string) error {
 }
 
 func copyMap(in map[string]bool) map[string]bool {
-	out := make(map[string]bool)
+	out := make(map[string]bool, len(in))
 	for key, value := range in {
 		out[key] = value
 	}
@@ -554,11 +689,12 @@ type CommitAncestors struct {
 // GetEstimateMidpointAgain gets a rough midpoint just based on the middle commit in the graph??
 // DIVISIONS is the parameter to play around with, for the number of samples to take from the middle fifth section
 // It also add all the merge commits to this, just for fun
-func (d *DAG) GetEstimateMidpointAgain(c ParamConfig) (string, error) {
+// It gives up with ctx.Err() if the context is cancelled before all of the samples are in
+func (d *DAG) GetEstimateMidpointAgain(ctx context.Context, c ParamConfig) (string, error) {
 
 	leafs := d.GetLeafs()
 	var maxValue CommitAncestors
-	total := len(d.GetVertices())
+	total := d.GetMass()
 
 	tovisit := d.GetNMerges(c.Merges)
 
@@ -583,92 +719,201 @@ func (d *DAG) GetEstimateMidpointAgain(c ParamConfig) (string, error) {
 		}
 	}
 
-	// Get the number of jobs
-	numJobs := len(tovisit)
-
-	// Spawn workers
-	jobs := make(chan string, numJobs)
-	results := make(chan CommitAncestors, numJobs)
-	for w := 1; w <= int(math.Min(float64(runtime.GOMAXPROCS(0)), float64(len(tovisit)))); w++ {
-		go worker(w, d, jobs, results)
+	// Count the ancestors of everything we want to visit
+	commits := make([]string, 0, len(tovisit))
+	for k := range tovisit {
+		if d.IsEligible(k) {
+			commits = append(commits, k)
+		}
 	}
 
-	// Submit jobs
-	for k := range tovisit {
-		jobs <- k
+	counts, err := d.getPool().CountAncestors(ctx, d, commits)
+	if err != nil {
+		return "", err
 	}
-	close(jobs)
 
-	// Retrieve results
-	for a := 1; a <= numJobs; a++ {
-		result := <-results
-		result.Value = math.Min(float64(result.Value), float64(total)-float64(result.Value))
+	costs := d.GetCosts()
+	for _, result := range counts {
+		result.Value = score(result, total, costs)
 		if result.Value >= maxValue.Value {
 			maxValue = result
 		}
 	}
-	close(results)
 
 	return maxValue.Commit, nil
 }
 
 // GetMidPoint literally just returns the midpoint
-func (d *DAG) GetMidPoint(c ParamConfig) (string, error) {
-
-	if d.GetOrder() > c.Limit {
-		// log.Print("estimating...")
-		return d.GetEstimateMidpointAgain(c)
+// Cancelling the context stops the pool's workers, and GetMidPoint returns ctx.Err()
+// It returns "" if none of the commits left can be tested, or the bisection isn't limited to any of them (see SetOnly).
+func (d *DAG) GetMidPoint(ctx context.Context, c ParamConfig) (string, error) {
+
+	switch c.Strategy {
+	case "", StrategyAuto:
+		if d.GetOrder() > c.Limit {
+			// log.Print("estimating...")
+			return d.GetEstimateMidpointAgain(ctx, c)
+		}
+	case StrategyEstimate:
+		// Small DAGs may not have anything to sample, in which case do it properly
+		midpoint, err := d.GetEstimateMidpointAgain(ctx, c)
+		if err != nil || midpoint != "" {
+			return midpoint, err
+		}
+	case StrategyExact:
+	default:
+		return "", fmt.Errorf("unknown strategy '%s'", c.Strategy)
 	}
 
 	var maxValue CommitAncestors
 
 	temp := make(map[string]bool)
 	temp = d.GetVertices()
-	numJobs := len(temp)
+	commits := make([]string, 0, len(temp))
+	for j := range temp {
+		if d.IsEligible(j) {
+			commits = append(commits, j)
+		}
+	}
+
+	numJobs := len(commits)
+	if numJobs == 0 {
+		return "", nil
+	}
 	if numJobs == 1 {
-		var thing string
-		for s := range temp {
-			thing = s
+		thing := commits[0]
+		if math.IsInf(d.GetCost(thing), 1) {
+			return "", nil
 		}
 		return thing, nil
 	}
 
-	jobs := make(chan string, numJobs)
-	results := make(chan CommitAncestors, numJobs)
+	// The pool's workers count the ancestors of every commit
+	counts, err := d.getPool().CountAncestors(ctx, d, commits)
+	if err != nil {
+		return "", err
+	}
 
-	// We want to spawn some workers, who have a recieving commit channel, and send back results to a channel.
+	// With weights this splits the weight of the candidates rather than their number, and with costs it
+	// goes for the most information for the cost instead
+	total := d.GetMass()
+	costs := d.GetCosts()
+	for _, result := range counts {
+		result.Value = score(result, total, costs)
+		if result.Value >= maxValue.Value {
+			maxValue = result
+		}
+	}
+
+	return maxValue.Commit, nil
+}
 
-	for w := 1; w <= runtime.GOMAXPROCS(0); w++ {
-		go worker(w, d, jobs, results)
+// GetMidPointSkipping is GetMidPoint for when some commits can't be tested, like git bisect skip
+// If the midpoint is skipped, the best split among the other commits is used instead, looking at no more than c.Limit of them,
+// which are the ones nearest the midpoint (like git bisect skip picks a commit near it). Ties go to the nearest too.
+// It returns "" if every commit is skipped or can't be tested.
+func (d *DAG) GetMidPointSkipping(ctx context.Context, c ParamConfig, skip map[string]bool) (string, error) {
+	midpoint, err := d.GetMidPoint(ctx, c)
+	if err != nil || !skip[midpoint] {
+		return midpoint, err
 	}
 
-	for j := range temp {
-		jobs <- j
+	commits := d.nearest(midpoint, c.Limit, func(v string) bool {
+		return !skip[v] && d.eligible(v)
+	})
+	if len(commits) == 0 {
+		return "", nil
+	}
+	rank := make(map[string]int, len(commits))
+	for i, v := range commits {
+		rank[v] = i
 	}
 
-	close(jobs)
+	counts, err := d.getPool().CountAncestors(ctx, d, commits)
+	if err != nil {
+		return "", err
+	}
 
-	for a := 1; a <= numJobs; a++ {
-		result := <-results
-		result.Value = math.Min(float64(result.Value), float64(numJobs)-float64(result.Value))
-		if result.Value >= maxValue.Value {
+	var maxValue CommitAncestors
+	total := d.GetMass()
+	costs := d.GetCosts()
+	for _, result := range counts {
+		result.Value = score(result, total, costs)
+		if result.Value < 0 {
+			continue
+		}
+		if maxValue.Commit == "" || result.Value > maxValue.Value ||
+			(result.Value == maxValue.Value && rank[result.Commit] < rank[maxValue.Commit]) {
 			maxValue = result
 		}
 	}
-	close(results)
 
 	return maxValue.Commit, nil
 }
 
-func worker(id int, d *DAG, jobs <-chan string, results chan<- CommitAncestors) {
-	for j := range jobs {
-		ancs, err := d.GetAncestorsLength(j)
-		if err != nil {
-			log.Fatal(err)
+// nearest is up to n commits that keep is true for (n <= 0 for all of them), walking out from the commit through
+// parents and children so the closest come first. Commits as far away as each other are in name order, and any that
+// can't be reached from the commit come last. keep is called with the read lock held.
+func (d *DAG) nearest(from string, n int, keep func(string) bool) []string {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+
+	var found []string
+	seen := map[string]bool{from: true}
+	take := func(v string) bool {
+		if keep(v) {
+			found = append(found, v)
+		}
+		return n > 0 && len(found) >= n
+	}
+
+	level := []string{from}
+	for len(level) > 0 {
+		sort.Strings(level)
+		var next []string
+		for _, v := range level {
+			if take(v) {
+				return found
+			}
+			for _, edges := range []map[string]bool{d.inboundEdge[v], d.outboundEdge[v]} {
+				for u := range edges {
+					if !seen[u] {
+						seen[u] = true
+						next = append(next, u)
+					}
+				}
+			}
+		}
+		level = next
+	}
+
+	var rest []string
+	for v := range d.vertices {
+		if !seen[v] {
+			rest = append(rest, v)
 		}
-		results <- CommitAncestors{
-			Commit: j,
-			Value:  float64(ancs),
+	}
+	sort.Strings(rest)
+	for _, v := range rest {
+		if take(v) {
+			break
 		}
 	}
+	return found
+}
+
+// SetPool makes the DAG use its own worker pool for the midpoint selection, rather than the default one
+func (d *DAG) SetPool(p *Pool) {
+	d.muDAG.Lock()
+	defer d.muDAG.Unlock()
+	d.pool = p
+}
+
+func (d *DAG) getPool() *Pool {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	if d.pool == nil {
+		return DefaultPool()
+	}
+	return d.pool
 }
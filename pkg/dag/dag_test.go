@@ -0,0 +1,633 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newDAG builds a DAG from each commit's parents, in a fixed order so failures are repeatable
+func newDAG(t *testing.T, parents map[string][]string) *DAG {
+	var children []string
+	for child := range parents {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+
+	d := NewDAG()
+	for _, child := range children {
+		for _, parent := range parents[child] {
+			err := d.AddEdge(parent, child)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return d
+}
+
+func sortedVertices(d *DAG) []string {
+	vertices := []string{}
+	for v := range d.GetVertices() {
+		vertices = append(vertices, v)
+	}
+	sort.Strings(vertices)
+	return vertices
+}
+
+// header is the example from the comment at the top of dag.go
+var header = map[string][]string{
+	"B": {"A"},
+	"C": {"A"},
+	"D": {"B"},
+	"E": {"B", "C"},
+	"F": {"C"},
+	"G": {"D", "E"},
+}
+
+type step struct {
+	good   bool
+	commit string
+}
+
+func good(c string) step { return step{true, c} }
+func bad(c string) step  { return step{false, c} }
+
+func TestPruning(t *testing.T) {
+	tests := []struct {
+		name    string
+		parents map[string][]string
+		steps   []step
+		want    []string
+		wantBad string
+	}{
+		{
+			name:    "header good",
+			parents: header,
+			steps:   []step{good("B")},
+			want:    []string{"C", "D", "E", "F", "G"},
+		},
+		{
+			name:    "header good then bad",
+			parents: header,
+			steps:   []step{good("B"), bad("E")},
+			want:    []string{"C"},
+			wantBad: "E",
+		},
+		{
+			name:    "header bad keeps only ancestors",
+			parents: header,
+			steps:   []step{bad("D")},
+			want:    []string{"A", "B"},
+			wantBad: "D",
+		},
+		{
+			name:    "header bad merge",
+			parents: header,
+			steps:   []step{bad("G")},
+			want:    []string{"A", "B", "C", "D", "E"},
+			wantBad: "G",
+		},
+		{
+			name:    "header good leaf leaves other branches",
+			parents: header,
+			steps:   []step{good("F")},
+			want:    []string{"B", "D", "E", "G"},
+		},
+		{
+			name:    "header good root",
+			parents: header,
+			steps:   []step{good("A"), bad("G")},
+			want:    []string{"B", "C", "D", "E"},
+			wantBad: "G",
+		},
+		{
+			name:    "header down to nothing",
+			parents: header,
+			steps:   []step{good("B"), bad("E"), good("C")},
+			want:    []string{},
+			wantBad: "E",
+		},
+		{
+			name:    "chain",
+			parents: map[string][]string{"2": {"1"}, "3": {"2"}, "4": {"3"}, "5": {"4"}},
+			steps:   []step{good("2"), bad("4")},
+			want:    []string{"3"},
+			wantBad: "4",
+		},
+		{
+			name:    "later bad replaces most recent bad",
+			parents: map[string][]string{"2": {"1"}, "3": {"2"}, "4": {"3"}, "5": {"4"}},
+			steps:   []step{bad("5"), bad("3")},
+			want:    []string{"1", "2"},
+			wantBad: "3",
+		},
+		{
+			name: "merge good on one side",
+			parents: map[string][]string{
+				"B": {"A"}, "C": {"A"}, "M": {"B", "C"}, "N": {"M"},
+			},
+			steps:   []step{good("B"), bad("N")},
+			want:    []string{"C", "M"},
+			wantBad: "N",
+		},
+		{
+			name: "merge good on both sides",
+			parents: map[string][]string{
+				"B": {"A"}, "C": {"A"}, "M": {"B", "C"}, "N": {"M"},
+			},
+			steps:   []step{good("B"), good("C"), bad("N")},
+			want:    []string{"M"},
+			wantBad: "N",
+		},
+		{
+			name: "octopus good on one arm",
+			parents: map[string][]string{
+				"B": {"A"}, "C": {"A"}, "D": {"A"}, "E": {"A"}, "O": {"B", "C", "D", "E"},
+			},
+			steps:   []step{good("C"), bad("O")},
+			want:    []string{"B", "D", "E"},
+			wantBad: "O",
+		},
+		{
+			name: "octopus bad on one arm",
+			parents: map[string][]string{
+				"B": {"A"}, "C": {"A"}, "D": {"A"}, "E": {"A"}, "O": {"B", "C", "D", "E"},
+			},
+			steps:   []step{good("A"), bad("D")},
+			want:    []string{},
+			wantBad: "D",
+		},
+		{
+			name: "disconnected good leaves the other component",
+			parents: map[string][]string{
+				"2": {"1"}, "3": {"2"}, "y": {"x"}, "z": {"y"},
+			},
+			steps: []step{good("2")},
+			want:  []string{"3", "x", "y", "z"},
+		},
+		{
+			name: "disconnected bad drops the other component",
+			parents: map[string][]string{
+				"2": {"1"}, "3": {"2"}, "y": {"x"}, "z": {"y"},
+			},
+			steps:   []step{bad("3")},
+			want:    []string{"1", "2"},
+			wantBad: "3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDAG(t, tt.parents)
+			for _, s := range tt.steps {
+				var err error
+				if s.good {
+					err = d.GoodCommit(s.commit)
+				} else {
+					err = d.BadCommit(s.commit)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := sortedVertices(d); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got vertices %v, want %v", got, tt.want)
+			}
+			if d.MostRecentBad != tt.wantBad {
+				t.Errorf("got most recent bad %q, want %q", d.MostRecentBad, tt.wantBad)
+			}
+		})
+	}
+}
+
+func TestPruningRemovesEdges(t *testing.T) {
+	d := newDAG(t, header)
+	err := d.GoodCommit("B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C -> E, C -> F, D -> G and E -> G are all that is left
+	if size := d.GetSize(); size != 4 {
+		t.Fatalf("got %v edges, want 4\n%v", size, d)
+	}
+	ancestors, err := d.GetOrderedAncestors("G")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ancestors)
+	if want := []string{"C", "D", "E"}; !reflect.DeepEqual(ancestors, want) {
+		t.Fatalf("got ancestors of G %v, want %v", ancestors, want)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	d := newDAG(t, header)
+
+	if order := d.GetOrder(); order != 7 {
+		t.Errorf("got order %v, want 7", order)
+	}
+	if size := d.GetSize(); size != 8 {
+		t.Errorf("got size %v, want 8", size)
+	}
+	if leafs := d.GetLeafs(); !reflect.DeepEqual(leafs, map[string]bool{"F": true, "G": true}) {
+		t.Errorf("got leafs %v, want F and G", leafs)
+	}
+	if merges := d.GetNMerges(0); !reflect.DeepEqual(merges, map[string]bool{"E": true, "G": true}) {
+		t.Errorf("got merges %v, want E and G", merges)
+	}
+	if merges := d.GetNMerges(1); len(merges) != 1 {
+		t.Errorf("got %v merges, want 1", len(merges))
+	}
+
+	lengths := map[string]int{"A": 0, "B": 1, "C": 1, "D": 2, "E": 3, "F": 2, "G": 5}
+	for v, want := range lengths {
+		got, err := d.GetAncestorsLength(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %v ancestors of %v, want %v", got, v, want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(d *DAG) error
+		want error
+	}{
+		{"add empty src", func(d *DAG) error { return d.AddEdge("", "A") }, IdEmptyError{}},
+		{"add empty dst", func(d *DAG) error { return d.AddEdge("A", "") }, IdEmptyError{}},
+		{"add empty vertex", func(d *DAG) error { return d.AddVertex("") }, IdEmptyError{}},
+		{"add loop", func(d *DAG) error { return d.AddEdge("A", "A") }, SrcDstEqualError{"A", "A"}},
+		{"add duplicate", func(d *DAG) error { return d.AddEdge("A", "B") }, EdgeDuplicateError{"A", "B"}},
+		{"delete unknown edge", func(d *DAG) error { return d.DeleteEdge("A", "G") }, EdgeUnknownError{"A", "G"}},
+		{"delete edge unknown vertex", func(d *DAG) error { return d.DeleteEdge("A", "Z") }, VertexUnknownError{"Z"}},
+		{"delete edge loop", func(d *DAG) error { return d.DeleteEdge("A", "A") }, SrcDstEqualError{"A", "A"}},
+		{"delete unknown vertex", func(d *DAG) error { return d.DeleteVertex("Z") }, VertexUnknownError{"Z"}},
+		{"delete empty vertex", func(d *DAG) error { return d.DeleteVertex("") }, IdEmptyError{}},
+		{"good unknown", func(d *DAG) error { return d.GoodCommit("Z") }, VertexUnknownError{"Z"}},
+		{"bad unknown", func(d *DAG) error { return d.BadCommit("Z") }, VertexUnknownError{"Z"}},
+		{"ancestors unknown", func(d *DAG) error { _, err := d.GetOrderedAncestors("Z"); return err }, VertexUnknownError{"Z"}},
+		{"ancestors length unknown", func(d *DAG) error { _, err := d.GetAncestorsLength("Z"); return err }, VertexUnknownError{"Z"}},
+		{"walker unknown", func(d *DAG) error { _, _, err := d.AncestorsWalker("Z"); return err }, VertexUnknownError{"Z"}},
+		{"good twice", func(d *DAG) error { d.GoodCommit("B"); return d.GoodCommit("B") }, VertexUnknownError{"B"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDAG(t, header)
+			err := tt.do(d)
+			if err != tt.want {
+				t.Fatalf("got error %#v, want %#v", err, tt.want)
+			}
+			if err.Error() == "" {
+				t.Fatal("empty error message")
+			}
+		})
+	}
+}
+
+func TestDeleteVertexRemovesEdges(t *testing.T) {
+	d := newDAG(t, header)
+	err := d.DeleteVertex("E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := d.GetSize(); size != 5 {
+		t.Fatalf("got %v edges, want 5\n%v", size, d)
+	}
+	if length, _ := d.GetAncestorsLength("G"); length != 3 {
+		t.Fatalf("got %v ancestors of G, want D, B and A", length)
+	}
+	if err := d.AddEdge("C", "E"); err != nil {
+		t.Fatalf("couldn't add E back: %v", err)
+	}
+}
+
+// randomHistory makes parents for a random history, with merges, octopus merges and now and then a new root,
+// so it can have several disconnected components
+func randomHistory(r *rand.Rand, n int) map[string][]string {
+	parents := make(map[string][]string)
+	for i := 1; i < n; i++ {
+		if r.Intn(15) == 0 {
+			continue
+		}
+
+		k := 1
+		switch r.Intn(10) {
+		case 0:
+			k = 2
+		case 1:
+			k = 3 + r.Intn(3)
+		}
+
+		seen := make(map[int]bool)
+		child := fmt.Sprint(i)
+		for j := 0; j < k; j++ {
+			p := i - 1
+			if i > 8 {
+				p -= r.Intn(8)
+			}
+			if j > 0 {
+				p = r.Intn(i)
+			}
+			if !seen[p] {
+				seen[p] = true
+				parents[child] = append(parents[child], fmt.Sprint(p))
+			}
+		}
+	}
+	return parents
+}
+
+// isAncestor is the slow but obviously right way of telling if a is b or one of its ancestors
+func isAncestor(parents map[string][]string, a, b string) bool {
+	if a == b {
+		return true
+	}
+	for _, p := range parents[b] {
+		if isAncestor(parents, a, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// survives checks the culprit is still one of the candidates
+func survives(t *testing.T, d *DAG, culprit string) {
+	t.Helper()
+	if culprit != d.MostRecentBad && !d.GetVertices()[culprit] {
+		t.Fatalf("culprit %v was pruned, most recent bad is %v, left with %v", culprit, d.MostRecentBad, sortedVertices(d))
+	}
+}
+
+// TestCulpritSurvives bisects random histories with random culprits, answering truthfully,
+// and checks the culprit is never pruned and is the answer at the end
+func TestCulpritSurvives(t *testing.T) {
+	for seed := int64(0); seed < 300; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		parents := randomHistory(r, 10+r.Intn(60))
+
+		// Only commits with edges end up in the DAG
+		vertices := sortedVertices(newDAG(t, parents))
+		culprit := vertices[r.Intn(len(vertices))]
+
+		var bads, goods []string
+		for _, v := range vertices {
+			if isAncestor(parents, culprit, v) {
+				bads = append(bads, v)
+			} else {
+				goods = append(goods, v)
+			}
+		}
+
+		// Half the time ask about random commits, the rest of the time ask the midpoint like the solver
+		random := seed%2 == 0
+
+		t.Run(fmt.Sprintf("seed %v", seed), func(t *testing.T) {
+			d := newDAG(t, parents)
+			if len(goods) > 0 {
+				err := d.GoodCommit(goods[r.Intn(len(goods))])
+				if err != nil {
+					t.Fatal(err)
+				}
+				survives(t, d, culprit)
+			}
+			err := d.BadCommit(bads[r.Intn(len(bads))])
+			if err != nil {
+				t.Fatal(err)
+			}
+			survives(t, d, culprit)
+
+			for questions := 0; d.GetOrder() > 0; questions++ {
+				if questions > len(vertices) {
+					t.Fatalf("still going after %v questions", questions)
+				}
+
+				var q string
+				if random {
+					candidates := sortedVertices(d)
+					q = candidates[r.Intn(len(candidates))]
+				} else {
+					q, err = d.GetMidPoint(context.Background(), exact)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				if isAncestor(parents, culprit, q) {
+					err = d.BadCommit(q)
+				} else {
+					err = d.GoodCommit(q)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				survives(t, d, culprit)
+			}
+
+			if d.MostRecentBad != culprit {
+				t.Fatalf("finished with %v, but the culprit is %v", d.MostRecentBad, culprit)
+			}
+		})
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	d := newDAG(t, header)
+	c := d.Clone()
+
+	for _, s := range []step{good("B"), bad("E")} {
+		var err error
+		if s.good {
+			err = c.GoodCommit(s.commit)
+		} else {
+			err = c.BadCommit(s.commit)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := sortedVertices(c); !reflect.DeepEqual(got, []string{"C"}) {
+		t.Fatalf("clone has %v, want C", got)
+	}
+	sameDAG(t, d, newDAG(t, header))
+
+	// And the other way round, including adding edges
+	again := d.Clone()
+	err := d.BadCommit("D")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.AddEdge("A", "Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameDAG(t, again, newDAG(t, header))
+	if got := sortedVertices(c); !reflect.DeepEqual(got, []string{"C"}) {
+		t.Fatalf("clone has %v, want C", got)
+	}
+}
+
+// TestClonePrunesLikeTheOriginal prunes clones of clones of random DAGs, checking each one ends up the same
+// as pruning a freshly built DAG, and that the DAG they were cloned from never changes
+func TestClonePrunesLikeTheOriginal(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		parents := randomHistory(r, 10+r.Intn(60))
+		base := newDAG(t, parents)
+		vertices := sortedVertices(base)
+
+		clones := []*DAG{base.Clone()}
+		for i := 0; i < 3; i++ {
+			clones = append(clones, clones[len(clones)-1].Clone())
+		}
+
+		for _, c := range clones {
+			fresh := newDAG(t, parents)
+			for i := 0; i < 3 && fresh.GetOrder() > 0; i++ {
+				candidates := sortedVertices(fresh)
+				v := candidates[r.Intn(len(candidates))]
+				if r.Intn(2) == 0 {
+					fresh.GoodCommit(v)
+					c.GoodCommit(v)
+				} else {
+					fresh.BadCommit(v)
+					c.BadCommit(v)
+				}
+				sameDAG(t, c, fresh)
+			}
+		}
+
+		if got := sortedVertices(base); !reflect.DeepEqual(got, vertices) {
+			t.Fatalf("seed %v: the original went from %v commits to %v", seed, len(vertices), len(got))
+		}
+		sameDAG(t, base, newDAG(t, parents))
+	}
+}
+
+func BenchmarkCloneAndPrepare(b *testing.B) {
+	d := randomDAG(20000, 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c := d.Clone()
+		c.GoodCommit("1000")
+		c.BadCommit("19000")
+	}
+}
+
+// BenchmarkBuildAndPrepare is what every instance used to cost, building the DAG again
+func BenchmarkBuildAndPrepare(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		d := randomDAG(20000, 1)
+		d.GoodCommit("1000")
+		d.BadCommit("19000")
+	}
+}
+
+func TestGetMidPointSkipping(t *testing.T) {
+	chain := make(map[string][]string)
+	for i := 1; i < 10; i++ {
+		chain[fmt.Sprint(i)] = []string{fmt.Sprint(i - 1)}
+	}
+	d := newDAG(t, chain)
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	ctx := context.Background()
+
+	midpoint, err := d.GetMidPoint(ctx, exact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, err := d.GetMidPointSkipping(ctx, exact, nil); err != nil || next != midpoint {
+		t.Errorf("got %v (%v) skipping nothing, want %v", next, err, midpoint)
+	}
+
+	// The next best are either side of the midpoint
+	skip := map[string]bool{midpoint: true}
+	next, err := d.GetMidPointSkipping(ctx, exact, skip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := d.GetAncestorsLength(midpoint)
+	n, _ := d.GetAncestorsLength(next)
+	if n != m-1 && n != m+1 {
+		t.Errorf("got %v with %v ancestors skipping %v with %v", next, n, midpoint, m)
+	}
+
+	for v := range chain {
+		skip[v] = true
+	}
+	skip["0"] = true
+	if next, err := d.GetMidPointSkipping(ctx, exact, skip); err != nil || next != "" {
+		t.Errorf("got %v (%v) with everything skipped", next, err)
+	}
+}
+
+// With a limit, the commits looked at are the ones nearest the skipped midpoint, so the answer is always the same
+func TestGetMidPointSkippingLimited(t *testing.T) {
+	chain := make(map[string][]string)
+	for i := 1; i < 100; i++ {
+		chain[fmt.Sprintf("%02d", i)] = []string{fmt.Sprintf("%02d", i-1)}
+	}
+	d := newDAG(t, chain)
+	ctx := context.Background()
+	limited := ParamConfig{Strategy: StrategyExact, Divisions: 1, Limit: 4}
+
+	midpoint, err := d.GetMidPoint(ctx, limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skip := map[string]bool{midpoint: true}
+	first, err := d.GetMidPointSkipping(ctx, limited, skip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := d.GetAncestorsLength(midpoint)
+	n, _ := d.GetAncestorsLength(first)
+	if n != m-1 && n != m+1 {
+		t.Errorf("got %v with %v ancestors skipping %v with %v", first, n, midpoint, m)
+	}
+
+	for i := 0; i < 20; i++ {
+		if next, _ := d.GetMidPointSkipping(ctx, limited, skip); next != first {
+			t.Fatalf("got %v, then %v", first, next)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	d := newDAG(t, header)
+	if err := d.AddVertex("Z"); err != nil {
+		t.Fatal(err)
+	}
+	all := func(string) bool { return true }
+
+	tests := []struct {
+		n    int
+		keep func(string) bool
+		want []string
+	}{
+		{0, all, []string{"E", "B", "C", "G", "A", "D", "F", "Z"}},
+		{3, all, []string{"E", "B", "C"}},
+		{3, func(v string) bool { return v != "E" && v != "C" }, []string{"B", "G", "A"}},
+		{0, func(v string) bool { return v == "nope" }, nil},
+	}
+	for _, tt := range tests {
+		if got := d.nearest("E", tt.n, tt.keep); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v nearest: got %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package dag
+
+import (
+	"fmt"
+	"math"
+)
+
+// Estimate is how many more questions the bisection is likely to take, going by how well the next question splits the candidates
+// It assumes every later question splits the candidates in the same ratio, which holds for a chain and is roughly right for
+// most histories, but a DAG with a lot of wide merges splits worse the further in you go.
+type Estimate struct {
+	Candidates int
+	// Best is if every answer leaves the smaller side, and Worst if every answer leaves the bigger one
+	Best  int
+	Worst int
+	// Expected is the average, if every candidate is as likely to be the culprit as any other
+	Expected float64
+}
+
+func (e Estimate) String() string {
+	if e.Best == e.Worst {
+		return fmt.Sprintf("%v more questions", e.Worst)
+	}
+	return fmt.Sprintf("about %.1f more questions (%v to %v)", e.Expected, e.Best, e.Worst)
+}
+
+// EstimateSplit estimates the questions left among the candidates, when answering bad to the next question
+// would leave bad of them (the question and its ancestors) and answering good would leave the rest.
+func EstimateSplit(candidates, bad int) Estimate {
+	e := Estimate{Candidates: candidates}
+	if candidates <= 1 {
+		return e
+	}
+	if bad <= 0 || bad >= candidates {
+		// The question doesn't split them at all, which only happens without a most recent bad commit
+		e.Best, e.Worst, e.Expected = 1, candidates-1, float64(candidates-1)
+		return e
+	}
+
+	n := float64(candidates)
+	p := float64(bad) / n
+	small, large := math.Min(p, 1-p), math.Max(p, 1-p)
+
+	// The tolerance stops log(1000)/log(10) coming out a hair under 3
+	e.Best = int(math.Floor(math.Log(n)/-math.Log(small) + 1e-9))
+	e.Worst = int(math.Ceil(math.Log(n)/-math.Log(large) - 1e-9))
+	// Every answer tells us the binary entropy of the split, and we need log2(n) bits to find the culprit
+	entropy := -p*math.Log2(p) - (1-p)*math.Log2(1-p)
+	e.Expected = math.Log2(n) / entropy
+
+	// No amount of luck beats halving, and we never need more questions than there are other candidates
+	e.Best = clamp(e.Best, 1, candidates-1)
+	e.Worst = clamp(e.Worst, max(e.Best, LowerBound(candidates)), candidates-1)
+	e.Expected = math.Min(math.Max(e.Expected, float64(e.Best)), float64(e.Worst))
+	return e
+}
+
+// GetEstimate estimates the questions left, going by how the question splits the DAG as it is now
+// Only the commits the bisection is limited to are counted, see SetOnly.
+func (d *DAG) GetEstimate(question string) (Estimate, error) {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	if err := d.saneVertex(question); err != nil {
+		return Estimate{}, err
+	}
+
+	// The question is bad along with its ancestors
+	bad := 1
+	d.visitAncestors(question, func(ancestor string) {
+		if d.eligible(ancestor) {
+			bad++
+		}
+	})
+
+	candidates := 0
+	for v := range d.vertices {
+		if d.eligible(v) {
+			candidates++
+		}
+	}
+	if d.MostRecentBad != "" {
+		candidates++
+	}
+	return EstimateSplit(candidates, bad), nil
+}
+
+func clamp(x, lo, hi int) int {
+	if x < lo {
+		return lo
+	}
+	if x > hi {
+		return hi
+	}
+	return x
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestEstimateSplit(t *testing.T) {
+	tests := []struct {
+		candidates, bad int
+		best, worst     int
+		expected        float64
+	}{
+		{0, 0, 0, 0, 0},
+		{1, 1, 0, 0, 0},
+		{2, 1, 1, 1, 1},
+		{1024, 512, 10, 10, 10},
+		{100, 50, 6, 7, 6.64},
+		{1000, 100, 3, 66, 21.2},
+		{1000, 900, 3, 66, 21.2},
+		{10, 10, 1, 9, 9},
+	}
+	for _, tt := range tests {
+		e := EstimateSplit(tt.candidates, tt.bad)
+		if e.Best != tt.best || e.Worst != tt.worst || fmt.Sprintf("%.1f", e.Expected) != fmt.Sprintf("%.1f", tt.expected) {
+			t.Errorf("EstimateSplit(%v, %v) = %+v, want best %v, worst %v and expected %.1f", tt.candidates, tt.bad, e, tt.best, tt.worst, tt.expected)
+		}
+	}
+}
+
+// On a chain every question halves the candidates, so the estimate should hold for every culprit.
+// GetMidPoint halves the DAG without the most recent bad commit though, which can be one off and cost one more question.
+func TestEstimateChain(t *testing.T) {
+	chain := make(map[string][]string)
+	for i := 1; i < 100; i++ {
+		chain[fmt.Sprint(i)] = []string{fmt.Sprint(i - 1)}
+	}
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	ctx := context.Background()
+
+	total := 0
+	var e Estimate
+	for culprit := 1; culprit < 100; culprit++ {
+		d := newDAG(t, chain)
+		d.BadCommit("99")
+		d.GoodCommit("0")
+
+		q, err := d.GetMidPoint(ctx, exact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err = d.GetEstimate(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		questions := 0
+		for ; d.GetOrder() > 0; questions++ {
+			q, err = d.GetMidPoint(ctx, exact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var n int
+			fmt.Sscan(q, &n)
+			if n >= culprit {
+				d.BadCommit(q)
+			} else {
+				d.GoodCommit(q)
+			}
+		}
+		if questions < e.Best || questions > e.Worst+1 {
+			t.Errorf("culprit %v took %v questions, estimated %v", culprit, questions, e)
+		}
+		total += questions
+	}
+	if mean := float64(total) / 99; mean < e.Expected-0.5 || mean > e.Expected+0.5 {
+		t.Errorf("took %.2f questions on average, estimated %v", mean, e)
+	}
+}
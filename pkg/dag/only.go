@@ -0,0 +1,55 @@
+package dag
+
+// SetOnly limits the bisection to the given commits, like git bisect --first-parent or git bisect -- <paths>
+// Only these commits are asked about or can be the culprit, but GoodCommit and BadCommit still rule out
+// commits through the whole graph, so the answers mean the same as they always do. nil goes back to every commit.
+// The commits are shared with clones, so they must not be changed afterwards.
+func (d *DAG) SetOnly(only map[string]bool) {
+	d.muDAG.Lock()
+	defer d.muDAG.Unlock()
+	d.only = only
+}
+
+// GetOnly returns the commits given to SetOnly, or nil
+func (d *DAG) GetOnly() map[string]bool {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	return d.only
+}
+
+// IsEligible is whether the commit is one the bisection is limited to, which is every commit without SetOnly
+func (d *DAG) IsEligible(v string) bool {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	return d.eligible(v)
+}
+
+// eligible is IsEligible for when the caller holds the lock
+func (d *DAG) eligible(v string) bool {
+	return d.only == nil || d.only[v]
+}
+
+// weight is the weight of the commit, which is 0 if the bisection isn't limited to it. The caller must hold the read lock.
+func (d *DAG) weight(v string) float64 {
+	if !d.eligible(v) {
+		return 0
+	}
+	return d.weights.of(v)
+}
+
+// GetEligibleOrder is how many commits are left that the bisection is limited to, which is GetOrder without SetOnly
+func (d *DAG) GetEligibleOrder() int {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+
+	if d.only == nil {
+		return len(d.vertices)
+	}
+	n := 0
+	for v := range d.vertices {
+		if d.only[v] {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,143 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// badFor answers like a human would if culprit is the first bad commit
+func badFor(t *testing.T, d *DAG, culprit string) func(string) bool {
+	return func(q string) bool {
+		if q == culprit {
+			return true
+		}
+		ancestors, err := d.GetOrderedAncestors(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range ancestors {
+			if v == culprit {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// bisectOnly bisects from good to bad limited to only, checking every question is one of them
+func bisectOnly(t *testing.T, parents map[string][]string, good, bad string, only map[string]bool, isBad func(string) bool) *Candidates {
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	c := NewCandidates(newDAG(t, parents))
+	if err := c.Good(good); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Bad(bad); err != nil {
+		t.Fatal(err)
+	}
+	c.SetOnly(only)
+
+	for c.View().GetEligibleOrder() > 0 {
+		q, err := c.View().GetMidPoint(context.Background(), exact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !only[q] {
+			t.Fatalf("asked about %v, which the bisection isn't limited to", q)
+		}
+		if err := c.Answer(Answer{Commit: q, Good: !isBad(q)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// m0 <- ... <- m9 is the main branch, and s1 <- ... <- s5 a branch off m2 that is merged in by m6
+func TestFirstParentOnly(t *testing.T) {
+	parents := map[string][]string{"s1": {"m2"}}
+	for i := 1; i < 10; i++ {
+		parents[fmt.Sprint("m", i)] = []string{fmt.Sprint("m", i-1)}
+	}
+	for i := 2; i <= 5; i++ {
+		parents[fmt.Sprint("s", i)] = []string{fmt.Sprint("s", i-1)}
+	}
+	parents["m6"] = append(parents["m6"], "s5")
+
+	only := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		only[fmt.Sprint("m", i)] = true
+	}
+
+	// The bug came in on the branch, so the merge is the first bad commit on the main branch
+	c := bisectOnly(t, parents, "m0", "m9", only, badFor(t, newDAG(t, parents), "s3"))
+	if c.MostRecentBad() != "m6" {
+		t.Errorf("found %v, want the merge m6", c.MostRecentBad())
+	}
+	if c.Len() != 1 {
+		t.Errorf("%v candidates left", c.Len())
+	}
+
+	// The branch was never asked about, but its commits still went the right way
+	if s, _ := c.Status("s4"); s != Excluded {
+		t.Errorf("s4 is %v, want excluded", s)
+	}
+	if s, _ := c.Status("m7"); s != RuledBad {
+		t.Errorf("m7 is %v, want ruled bad", s)
+	}
+	if s, _ := c.Status("m1"); s != RuledGood {
+		t.Errorf("m1 is %v, want ruled good", s)
+	}
+
+	// Undoing keeps the limit
+	c.Undo()
+	if c.View().GetOnly() == nil || c.View().GetEligibleOrder() == c.View().GetOrder() {
+		t.Errorf("lost the limit after an undo")
+	}
+}
+
+// Whatever the commits are limited to, the bisection ends at a bad one whose eligible ancestors are all good
+func TestOnlyFindsFirstBadEligible(t *testing.T) {
+	tested := 0
+	for seed := int64(0); seed < 100; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		parents := randomHistory(r, 10+r.Intn(40))
+		base := newDAG(t, parents)
+		vertices := sortedVertices(base)
+
+		bad := vertices[len(vertices)-1]
+		ancestors, _ := base.GetOrderedAncestors(bad)
+		if len(ancestors) < 3 {
+			continue
+		}
+		good := ancestors[len(ancestors)-1]
+		culprit := ancestors[r.Intn(len(ancestors)-1)]
+		isBad := badFor(t, base, culprit)
+		if !isBad(bad) || isBad(good) {
+			continue
+		}
+
+		only := map[string]bool{bad: true}
+		for _, v := range vertices {
+			if r.Intn(3) == 0 {
+				only[v] = true
+			}
+		}
+
+		tested++
+		c := bisectOnly(t, parents, good, bad, only, isBad)
+		found := c.MostRecentBad()
+		if !only[found] || !isBad(found) {
+			t.Fatalf("seed %v: found %v, which is eligible %v and bad %v", seed, found, only[found], isBad(found))
+		}
+		before, _ := base.GetOrderedAncestors(found)
+		for _, v := range before {
+			if only[v] && isBad(v) {
+				t.Fatalf("seed %v: found %v, but its eligible ancestor %v is bad too", seed, found, v)
+			}
+		}
+	}
+	if tested < 50 {
+		t.Errorf("only %v of the histories could be bisected", tested)
+	}
+}
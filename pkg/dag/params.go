@@ -0,0 +1,33 @@
+package dag
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// LoadParamConfig reads a ParamConfig from a json file, such as the one written by cmd/tune
+func LoadParamConfig(path string) (ParamConfig, error) {
+	var c ParamConfig
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// SaveParamConfig writes the ParamConfig to a json file
+func SaveParamConfig(path string, c ParamConfig) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,124 @@
+package dag
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// started counts every goroutine the package starts, so the benchmarks can show how many each question costs
+var started int64
+
+// Pool is a fixed set of long-lived workers that count ancestors for the midpoint selection.
+// Rather than starting fresh goroutines for every question, every DAG shares the default pool
+// (GOMAXPROCS workers, started on first use) unless it is given its own with SetPool.
+type Pool struct {
+	jobs      chan poolJob
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type poolJob struct {
+	ctx     context.Context
+	d       *DAG
+	commit  string
+	results chan<- poolResult
+}
+
+type poolResult struct {
+	CommitAncestors
+	err error
+}
+
+var (
+	defaultPool     *Pool
+	defaultPoolOnce sync.Once
+)
+
+// DefaultPool returns the shared pool used by any DAG without its own
+func DefaultPool() *Pool {
+	defaultPoolOnce.Do(func() {
+		defaultPool = NewPool(runtime.GOMAXPROCS(0))
+	})
+	return defaultPool
+}
+
+// NewPool starts a pool with the given number of workers, which run until Close is called
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &Pool{
+		jobs: make(chan poolJob),
+	}
+
+	p.wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		atomic.AddInt64(&started, 1)
+		go p.worker()
+	}
+
+	return p
+}
+
+// Close stops the workers once they have finished what they are doing
+// The pool must not be used after it is closed.
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.jobs)
+	})
+	p.wg.Wait()
+}
+
+// worker works out the number (or weight) of candidates left if each job is bad, and skips the job if its context is already cancelled
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	for job := range p.jobs {
+		result := poolResult{CommitAncestors: CommitAncestors{Commit: job.commit}}
+
+		if err := job.ctx.Err(); err != nil {
+			result.err = err
+		} else {
+			ancs, err := job.d.GetBadMass(job.commit)
+			result.Value = ancs
+			result.err = err
+		}
+
+		// The results channel always has room for every job, so this never blocks
+		job.results <- result
+	}
+}
+
+// CountAncestors counts every commit along with its ancestors using the pool's workers, which is what is left if it is bad
+// It returns the first error from a worker, or ctx.Err() as soon as the context is cancelled.
+func (p *Pool) CountAncestors(ctx context.Context, d *DAG, commits []string) ([]CommitAncestors, error) {
+	results := make(chan poolResult, len(commits))
+
+	submitted := 0
+	for _, commit := range commits {
+		select {
+		case p.jobs <- poolJob{ctx, d, commit, results}:
+			submitted++
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	counts := make([]CommitAncestors, 0, submitted)
+	for a := 0; a < submitted; a++ {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				return nil, result.err
+			}
+			counts = append(counts, result.CommitAncestors)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return counts, nil
+}
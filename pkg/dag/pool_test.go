@@ -0,0 +1,170 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// randomDAG makes a DAG where every commit has one or two earlier parents, a bit like a real history
+func randomDAG(n int, seed int64) *DAG {
+	r := rand.New(rand.NewSource(seed))
+	d := NewDAG()
+	for i := 1; i < n; i++ {
+		child := fmt.Sprint(i)
+		d.AddEdge(fmt.Sprint(i-1), child)
+		if i > 2 && r.Intn(4) == 0 {
+			d.AddEdge(fmt.Sprint(r.Intn(i-1)), child)
+		}
+	}
+	return d
+}
+
+var exact = ParamConfig{Limit: 5000, Divisions: 50, Merges: 100, Strategy: StrategyExact}
+
+func TestGetMidPointNoGoroutineLeak(t *testing.T) {
+	d := randomDAG(200, 1)
+
+	// Warm up the default pool, so its workers are already counted
+	_, err := d.GetMidPoint(context.Background(), exact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		_, err := d.GetMidPoint(context.Background(), exact)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Give anything that did escape a chance to show up
+	time.Sleep(10 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines went from %v to %v", before, after)
+	}
+}
+
+func TestPoolCancelled(t *testing.T) {
+	d := randomDAG(200, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := d.GetMidPoint(ctx, exact)
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestPoolError(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+
+	d := randomDAG(10, 3)
+
+	_, err := p.CountAncestors(context.Background(), d, []string{"1", "nope", "2"})
+	if _, ok := err.(VertexUnknownError); !ok {
+		t.Errorf("expected a VertexUnknownError, got %v", err)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	p := NewPool(4)
+	d := randomDAG(10, 4)
+	d.SetPool(p)
+	_, err := d.GetMidPoint(context.Background(), exact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines went from %v to %v after Close", before, after)
+	}
+}
+
+// Once the pool's workers are running, questions don't start any more goroutines
+func TestPoolStartsNoGoroutines(t *testing.T) {
+	p := NewPool(4)
+	defer p.Close()
+	d := randomDAG(100, 7)
+	d.SetPool(p)
+
+	before := atomic.LoadInt64(&started)
+	for i := 0; i < 5; i++ {
+		if _, err := d.GetMidPoint(context.Background(), exact); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := atomic.LoadInt64(&started); after != before {
+		t.Errorf("5 questions started %v goroutines", after-before)
+	}
+}
+
+// BenchmarkGetMidPoint reports the goroutines started per question, which is 0 once the pool is running
+// (it used to be one for every commit)
+func BenchmarkGetMidPoint(b *testing.B) {
+	d := randomDAG(1000, 5)
+	ctx := context.Background()
+
+	// Start the default pool first
+	_, err := d.GetMidPoint(ctx, exact)
+	if err != nil {
+		b.Fatal(err)
+	}
+	before := atomic.LoadInt64(&started)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := d.GetMidPoint(ctx, exact)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(atomic.LoadInt64(&started)-before)/float64(b.N), "goroutines/op")
+}
+
+// BenchmarkAncestorsWalker is how the ancestors used to be counted, with a goroutine and channel per call
+func BenchmarkAncestorsWalker(b *testing.B) {
+	d := randomDAG(1000, 6)
+	before := atomic.LoadInt64(&started)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vertices, _, err := d.AncestorsWalker("999")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for range vertices {
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(atomic.LoadInt64(&started)-before)/float64(b.N), "goroutines/op")
+}
+
+// BenchmarkGetAncestorsLength is how the ancestors are counted now
+func BenchmarkGetAncestorsLength(b *testing.B) {
+	d := randomDAG(1000, 6)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := d.GetAncestorsLength("999")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package dag
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// binaryMagic starts every binary DAG file, with the format version at the end
+const binaryMagic = "gitbisect-dag\x01"
+
+// maxCommitLength stops a corrupt file making us allocate something silly
+const maxCommitLength = 1 << 16
+
+// Save writes the DAG to the file, as JSON if it ends in .json and in the compact binary format otherwise
+// The file is written next to the path and renamed into place, so a reader never sees half of it.
+func (d *DAG) Save(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.NewEncoder(f).Encode(d)
+	} else {
+		err = d.WriteBinary(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Load reads a DAG written by Save
+func Load(path string) (*DAG, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		d := NewDAG()
+		err = json.NewDecoder(f).Decode(d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return d, nil
+	}
+
+	d, err := ReadBinary(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return d, nil
+}
+
+// WriteBinary writes the DAG in the compact binary format:
+// the magic, the number of commits, every commit (as its length and then the bytes) in name order,
+// the parents of every commit (as the number of parents and then their indexes), and finally the most recent bad commit.
+// All the numbers are uvarints.
+func (d *DAG) WriteBinary(w io.Writer) error {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+
+	commits := make([]string, 0, len(d.vertices))
+	for v := range d.vertices {
+		commits = append(commits, v)
+	}
+	sort.Strings(commits)
+	index := make(map[string]uint64, len(commits))
+	for i, v := range commits {
+		index[v] = uint64(i)
+	}
+
+	bw := &binaryWriter{w: bufio.NewWriter(w)}
+	bw.string(binaryMagic)
+	bw.uvarint(uint64(len(commits)))
+	for _, v := range commits {
+		bw.uvarint(uint64(len(v)))
+		bw.string(v)
+	}
+
+	parents := make([]uint64, 0)
+	for _, v := range commits {
+		parents = parents[:0]
+		for parent := range d.inboundEdge[v] {
+			parents = append(parents, index[parent])
+		}
+		sort.Slice(parents, func(i, j int) bool { return parents[i] < parents[j] })
+
+		bw.uvarint(uint64(len(parents)))
+		for _, p := range parents {
+			bw.uvarint(p)
+		}
+	}
+
+	bw.uvarint(uint64(len(d.MostRecentBad)))
+	bw.string(d.MostRecentBad)
+
+	if bw.err != nil {
+		return bw.err
+	}
+	return bw.w.Flush()
+}
+
+// ReadBinary reads a DAG written by WriteBinary
+func ReadBinary(r io.ByteReader) (*DAG, error) {
+	br := &binaryReader{r: r}
+	if magic := br.bytes(uint64(len(binaryMagic))); br.err == nil && magic != binaryMagic {
+		return nil, errors.New("not a binary DAG file")
+	}
+
+	n := br.uvarint()
+	if br.err != nil {
+		return nil, br.error()
+	}
+
+	d := NewDAG()
+	commits := make([]string, 0)
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		v := br.commit()
+		commits = append(commits, v)
+		d.vertices[v] = true
+	}
+
+	for _, v := range commits {
+		k := br.uvarint()
+		for j := uint64(0); j < k && br.err == nil; j++ {
+			p := br.uvarint()
+			if br.err == nil && p >= uint64(len(commits)) {
+				return nil, fmt.Errorf("parent %v of %s is out of range", p, v)
+			}
+			if br.err != nil {
+				break
+			}
+			parent := commits[p]
+			if parent == v {
+				return nil, SrcDstEqualError{parent, v}
+			}
+			if d.inboundEdge[v] == nil {
+				d.inboundEdge[v] = make(map[string]bool)
+			}
+			if d.outboundEdge[parent] == nil {
+				d.outboundEdge[parent] = make(map[string]bool)
+			}
+			d.inboundEdge[v][parent] = true
+			d.outboundEdge[parent][v] = true
+		}
+	}
+
+	if n := br.uvarint(); n > 0 {
+		d.MostRecentBad = br.bytes(n)
+	}
+
+	if br.err != nil {
+		return nil, br.error()
+	}
+	return d, nil
+}
+
+type binaryWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (bw *binaryWriter) uvarint(x uint64) {
+	if bw.err == nil {
+		_, bw.err = bw.w.Write(bw.buf[:binary.PutUvarint(bw.buf[:], x)])
+	}
+}
+
+func (bw *binaryWriter) string(s string) {
+	if bw.err == nil {
+		_, bw.err = bw.w.WriteString(s)
+	}
+}
+
+// binaryReader remembers the first error, like errWriter in the report package
+type binaryReader struct {
+	r   io.ByteReader
+	err error
+}
+
+func (br *binaryReader) uvarint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+	var x uint64
+	x, br.err = binary.ReadUvarint(br.r)
+	return x
+}
+
+func (br *binaryReader) bytes(n uint64) string {
+	if br.err != nil {
+		return ""
+	}
+	if n > maxCommitLength {
+		br.err = fmt.Errorf("commit of %v bytes is too long", n)
+		return ""
+	}
+	b := make([]byte, n)
+	for i := range b {
+		b[i], br.err = br.r.ReadByte()
+		if br.err != nil {
+			return ""
+		}
+	}
+	return string(b)
+}
+
+// error is the first error, where running out of file part way through is unexpected
+func (br *binaryReader) error() error {
+	if br.err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return br.err
+}
+
+func (br *binaryReader) commit() string {
+	v := br.bytes(br.uvarint())
+	if br.err == nil && v == "" {
+		br.err = IdEmptyError{}
+	}
+	return v
+}
+
+// dagJSON is the JSON form of a DAG, the parents of every commit (an empty list for the roots)
+type dagJSON struct {
+	MostRecentBad string              `json:"most_recent_bad,omitempty"`
+	Parents       map[string][]string `json:"parents"`
+}
+
+// MarshalJSON writes the DAG as {"most_recent_bad": "...", "parents": {"commit": ["parent", ...]}}
+func (d *DAG) MarshalJSON() ([]byte, error) {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+
+	out := dagJSON{
+		MostRecentBad: d.MostRecentBad,
+		Parents:       make(map[string][]string, len(d.vertices)),
+	}
+	for v := range d.vertices {
+		parents := make([]string, 0, len(d.inboundEdge[v]))
+		for parent := range d.inboundEdge[v] {
+			parents = append(parents, parent)
+		}
+		sort.Strings(parents)
+		out.Parents[v] = parents
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads the DAG written by MarshalJSON, replacing whatever was in it
+func (d *DAG) UnmarshalJSON(data []byte) error {
+	var in dagJSON
+	err := json.Unmarshal(data, &in)
+	if err != nil {
+		return err
+	}
+
+	loaded := NewDAG()
+	for v := range in.Parents {
+		if v == "" {
+			return IdEmptyError{}
+		}
+		loaded.vertices[v] = true
+	}
+	for v, parents := range in.Parents {
+		for _, parent := range parents {
+			if !loaded.vertices[parent] {
+				return VertexUnknownError{parent}
+			}
+			err = loaded.AddEdge(parent, v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	d.muDAG.Lock()
+	defer d.muDAG.Unlock()
+	d.vertices = loaded.vertices
+	d.inboundEdge = loaded.inboundEdge
+	d.outboundEdge = loaded.outboundEdge
+	d.MostRecentBad = in.MostRecentBad
+	d.cow, d.ownIn, d.ownOut = false, nil, nil
+	return nil
+}
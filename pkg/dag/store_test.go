@@ -0,0 +1,127 @@
+package dag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sameDAG checks two DAGs have the same commits, edges and most recent bad
+func sameDAG(t *testing.T, got, want *DAG) {
+	t.Helper()
+	if !reflect.DeepEqual(got.vertices, want.vertices) {
+		t.Fatalf("got %v commits, want %v", len(got.vertices), len(want.vertices))
+	}
+	for v := range want.vertices {
+		if len(got.inboundEdge[v]) != len(want.inboundEdge[v]) || len(got.outboundEdge[v]) != len(want.outboundEdge[v]) {
+			t.Fatalf("%v has %v parents and %v children, want %v and %v", v,
+				len(got.inboundEdge[v]), len(got.outboundEdge[v]), len(want.inboundEdge[v]), len(want.outboundEdge[v]))
+		}
+		for parent := range want.inboundEdge[v] {
+			if !got.inboundEdge[v][parent] || !got.outboundEdge[parent][v] {
+				t.Fatalf("missing edge %v -> %v", parent, v)
+			}
+		}
+	}
+	if got.MostRecentBad != want.MostRecentBad {
+		t.Fatalf("got most recent bad %q, want %q", got.MostRecentBad, want.MostRecentBad)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	d := randomDAG(500, 1)
+	pruned := randomDAG(500, 2)
+	err := pruned.BadCommit("400")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"repo.dag", "repo.json"} {
+		for _, want := range []*DAG{d, pruned, NewDAG()} {
+			path := filepath.Join(t.TempDir(), name)
+			err := want.Save(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameDAG(t, got, want)
+		}
+	}
+}
+
+func TestBinaryIsSmallerThanJSON(t *testing.T) {
+	d := randomDAG(2000, 1)
+
+	var b bytes.Buffer
+	err := d.WriteBinary(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Len() >= len(j) {
+		t.Fatalf("binary is %v bytes, JSON is %v", b.Len(), len(j))
+	}
+}
+
+func TestReadBinaryErrors(t *testing.T) {
+	var b bytes.Buffer
+	err := newDAG(t, header).WriteBinary(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := b.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, io.ErrUnexpectedEOF.Error()},
+		{"not a dag", []byte("definitely not a DAG file"), "not a binary DAG file"},
+		{"truncated", good[:len(good)-3], io.ErrUnexpectedEOF.Error()},
+		{"parent out of range", append([]byte(binaryMagic), 1, 1, 'a', 1, 5, 0), "out of range"},
+		{"own parent", append([]byte(binaryMagic), 1, 1, 'a', 1, 0, 0), "equal"},
+		{"empty commit", append([]byte(binaryMagic), 1, 0, 0, 0), "nil"},
+		{"huge commit", append([]byte(binaryMagic), 1, 0xff, 0xff, 0xff, 0x0f), "too long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadBinary(bufio.NewReader(bytes.NewReader(tt.data)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		want error
+	}{
+		{`{"parents": {"a": ["b"]}}`, VertexUnknownError{"b"}},
+		{`{"parents": {"": []}}`, IdEmptyError{}},
+		{`{"parents": {"a": ["a"]}}`, SrcDstEqualError{"a", "a"}},
+		{`{"parents": {"a": ["b", "b"], "b": []}}`, EdgeDuplicateError{"b", "a"}},
+	}
+
+	for _, tt := range tests {
+		err := json.Unmarshal([]byte(tt.data), NewDAG())
+		if err != tt.want {
+			t.Errorf("%v: got error %#v, want %#v", tt.data, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+// Weights are how likely each commit is to be the culprit before we ask anything, such as more for huge merges
+// or commits touching the failing area. Any commit that isn't given has a weight of 1, and the weights don't need to add up to anything.
+type Weights map[string]float64
+
+// LoadWeights reads weights from a json file, {"<sha>": 2.5, ...}
+func LoadWeights(path string) (Weights, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var w Weights
+	err = json.Unmarshal(data, &w)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for commit, weight := range w {
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("%s: weight of %s must be a non-negative number, got %v", path, commit, weight)
+		}
+	}
+	return w, nil
+}
+
+// of is the weight of the commit
+func (w Weights) of(v string) float64 {
+	if weight, ok := w[v]; ok {
+		return weight
+	}
+	return 1
+}
+
+// SetWeights makes the midpoint selection split the weight of the candidates rather than their number, nil to go back to counting them
+// The weights are shared with clones, so they must not be changed afterwards.
+func (d *DAG) SetWeights(w Weights) {
+	d.muDAG.Lock()
+	defer d.muDAG.Unlock()
+	d.weights = w
+}
+
+// GetWeights returns the weights given to SetWeights, or nil
+func (d *DAG) GetWeights() Weights {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	return d.weights
+}
+
+// GetMass is the total weight of the candidates, the commits left and the most recent bad one,
+// which is just how many there are without weights. Commits the bisection isn't limited to (see SetOnly) weigh nothing.
+func (d *DAG) GetMass() float64 {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+
+	mass := 0.0
+	if d.weights == nil && d.only == nil {
+		mass = float64(len(d.vertices))
+	} else {
+		for v := range d.vertices {
+			mass += d.weight(v)
+		}
+	}
+	// The most recent bad is a candidate even if SetOnly didn't include it (see GetBound)
+	if d.MostRecentBad != "" && !d.vertices[d.MostRecentBad] {
+		mass += d.weights.of(d.MostRecentBad)
+	}
+	return mass
+}
+
+// GetBadMass is the total weight of v and its ancestors, which are the candidates left if v is bad
+func (d *DAG) GetBadMass(v string) (float64, error) {
+	d.muDAG.RLock()
+	defer d.muDAG.RUnlock()
+	if err := d.saneVertex(v); err != nil {
+		return 0, err
+	}
+
+	mass := d.weight(v)
+	d.visitAncestors(v, func(ancestor string) {
+		mass += d.weight(ancestor)
+	})
+	return mass, nil
+}
@@ -0,0 +1,172 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chainDAG is 0 <- 1 <- ... <- n-1
+func chainDAG(t testing.TB, n int) *DAG {
+	d := NewDAG()
+	for i := 1; i < n; i++ {
+		if err := d.AddEdge(fmt.Sprint(i-1), fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+// bisected is the chain 0 <- ... <- 10 with 0 good and 10 bad, so the candidates are 1 to 10
+func bisected(t *testing.T) *DAG {
+	d := chainDAG(t, 11)
+	if err := d.BadCommit("10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.GoodCommit("0"); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestWeightedMidPoint(t *testing.T) {
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		weights Weights
+		// bad is the weight left if 5 is bad, out of the total weight of the candidates
+		bad, total float64
+		want       string
+	}{
+		{"without weights", nil, 5, 10, "5"},
+		// Asking 9 leaves 9 if it is bad, or just 10 (weighing 10) if it is good
+		{"on the most recent bad", Weights{"10": 10}, 5, 19, "9"},
+		// 5 is on the bad side when it is asked about, which leaves 14 if it is bad and 5 if it is good
+		{"on the midpoint", Weights{"5": 10}, 14, 19, "5"},
+		{"on an old commit", Weights{"2": 100}, 104, 109, "2"},
+	}
+	for _, tt := range tests {
+		d := bisected(t)
+		d.SetWeights(tt.weights)
+
+		if bad, _ := d.GetBadMass("5"); bad != tt.bad || d.GetMass() != tt.total {
+			t.Errorf("%v: 5 leaves %v out of %v if it is bad, want %v out of %v", tt.name, bad, d.GetMass(), tt.bad, tt.total)
+		}
+		q, err := d.GetMidPoint(ctx, exact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, q, tt.want)
+		}
+	}
+
+	// Clones and candidates keep the weights, even after an undo
+	d := bisected(t)
+	d.SetWeights(Weights{"10": 10})
+	if clone := d.Clone(); clone.GetMass() != 19 {
+		t.Errorf("the clone has mass %v, want 19", clone.GetMass())
+	}
+	c := NewCandidates(bisected(t))
+	c.SetWeights(Weights{"10": 10})
+	c.Bad("7")
+	c.Undo()
+	if got, _ := c.View().GetMidPoint(ctx, exact); got != "9" {
+		t.Errorf("got %v after an undo, want 9", got)
+	}
+}
+
+// When the priors are right, splitting the weight should take fewer questions on average than splitting the count
+func TestWeightsHelpWhenRight(t *testing.T) {
+	const n = 200
+	r := rand.New(rand.NewSource(1))
+	w := Weights{}
+	for i := 0; i < 10; i++ {
+		w[fmt.Sprint(1+r.Intn(n-1))] = 50
+	}
+	var suspects []string
+	for commit, weight := range w {
+		for i := 0; i < int(weight); i++ {
+			suspects = append(suspects, commit)
+		}
+	}
+	for i := 1; i < n; i++ {
+		suspects = append(suspects, fmt.Sprint(i))
+	}
+
+	exact := ParamConfig{Strategy: StrategyExact, Divisions: 1}
+	questions := func(weights Weights, culprit int) int {
+		d := chainDAG(t, n)
+		d.BadCommit(fmt.Sprint(n - 1))
+		d.GoodCommit("0")
+		d.SetWeights(weights)
+
+		asked := 0
+		for ; d.GetOrder() > 0; asked++ {
+			q, err := d.GetMidPoint(context.Background(), exact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var i int
+			fmt.Sscan(q, &i)
+			if i >= culprit {
+				d.BadCommit(q)
+			} else {
+				d.GoodCommit(q)
+			}
+		}
+		return asked
+	}
+
+	// The culprits are drawn from the priors
+	weighted, counted := 0, 0
+	for trial := 0; trial < 100; trial++ {
+		var culprit int
+		fmt.Sscan(suspects[r.Intn(len(suspects))], &culprit)
+		weighted += questions(w, culprit)
+		counted += questions(nil, culprit)
+	}
+	if weighted >= counted {
+		t.Errorf("took %v questions with the weights and %v without", weighted, counted)
+	}
+}
+
+func TestLoadWeights(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{`{"a": 2.5, "b": 0}`, ""},
+		{`{"a": -1}`, "non-negative"},
+		{`["a"]`, "cannot unmarshal"},
+		{`{"a": "2"}`, "cannot unmarshal"},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%v.json", i))
+		if err := ioutil.WriteFile(path, []byte(tt.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		w, err := LoadWeights(path)
+		if tt.want == "" {
+			if err != nil || w.of("a") != 2.5 || w.of("b") != 0 || w.of("c") != 1 {
+				t.Errorf("%v: got %v (%v)", tt.file, w, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got error %v, want %q", tt.file, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package gen generates synthetic repositories in the shapes of the problem families the server uses
+// (tiny-chain, tiny-diamonds and so on), and test cases for them in the tests/*.json format.
+package gen
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+)
+
+// Family generates the history of a repo with about size commits, as the parents of every commit by index
+// Every commit's parents come before it, and commit 0 is the only root.
+type Family func(r *rand.Rand, size int) [][]int
+
+// Families are all the shapes we can generate, by name
+var Families = map[string]Family{
+	"chain":    Chain,
+	"diamonds": Diamonds,
+	"complete": Complete,
+	"random":   Random,
+	"branches": FeatureBranches,
+	"octopus":  Octopus,
+}
+
+// Sizes are the usual number of commits for each size class
+var Sizes = map[string]int{
+	"tiny":   20,
+	"small":  200,
+	"medium": 2000,
+	"large":  20000,
+}
+
+// Names is the sorted names of the families
+func Names() []string {
+	var names []string
+	for name := range Families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseSize reads a size class (tiny, small, medium, large) or a number of commits
+// A problem needs a good and a bad commit, so there have to be at least 2.
+func ParseSize(s string) (int, error) {
+	if size, ok := Sizes[s]; ok {
+		return size, nil
+	}
+	size, err := strconv.Atoi(s)
+	if err != nil || size < 2 {
+		return 0, fmt.Errorf("bad size '%s', expected tiny, small, medium, large or a number of commits (at least 2)", s)
+	}
+	return size, nil
+}
+
+// Chain is a straight line of commits
+func Chain(r *rand.Rand, size int) [][]int {
+	parents := [][]int{nil}
+	for len(parents) < size {
+		parents = append(parents, []int{len(parents) - 1})
+	}
+	return parents
+}
+
+// Diamonds is a line of diamonds, where each commit splits into two which are merged straight back together
+func Diamonds(r *rand.Rand, size int) [][]int {
+	parents := [][]int{nil}
+	for len(parents) < size {
+		base := len(parents) - 1
+		if size-len(parents) < 3 {
+			parents = append(parents, []int{base})
+			continue
+		}
+		parents = append(parents, []int{base}, []int{base}, []int{base + 1, base + 2})
+	}
+	return parents
+}
+
+// Complete has every commit as a parent of every later commit
+// It has size² edges, so keep it small.
+func Complete(r *rand.Rand, size int) [][]int {
+	parents := [][]int{nil}
+	for i := 1; i < size; i++ {
+		p := make([]int, i)
+		for j := range p {
+			p[j] = j
+		}
+		parents = append(parents, p)
+	}
+	return parents
+}
+
+// Random builds on one of the last few commits, and merges in any earlier commit about a third of the time
+func Random(r *rand.Rand, size int) [][]int {
+	parents := [][]int{nil}
+	for i := 1; i < size; i++ {
+		first := i - 1 - r.Intn(min(i, 5))
+		p := []int{first}
+		if i > 1 && r.Intn(3) == 0 {
+			if second := r.Intn(i); second != first {
+				p = append(p, second)
+			}
+		}
+		parents = append(parents, p)
+	}
+	return parents
+}
+
+// FeatureBranches has a few long-lived branches alongside master, which now and then merge master in
+// or get merged back into master, and are all merged into master at the end
+func FeatureBranches(r *rand.Rand, size int) [][]int {
+	parents := [][]int{nil}
+	// tips[0] is master
+	tips := make([]int, 2+r.Intn(3))
+
+	add := func(p ...int) int {
+		parents = append(parents, p)
+		return len(parents) - 1
+	}
+
+	// At least one commit after the root, so there is something to bisect even when the branches take up the whole size
+	for len(parents) < 2 || len(parents) < size-len(tips)+1 {
+		branch := 0
+		if r.Intn(2) == 0 {
+			branch = 1 + r.Intn(len(tips)-1)
+		}
+
+		switch {
+		case branch != 0 && tips[branch] != tips[0] && r.Intn(10) == 0:
+			tips[0] = add(tips[0], tips[branch])
+		case branch != 0 && tips[branch] != tips[0] && r.Intn(10) == 0:
+			tips[branch] = add(tips[branch], tips[0])
+		default:
+			tips[branch] = add(tips[branch])
+		}
+	}
+
+	for _, tip := range tips[1:] {
+		if tip != tips[0] {
+			tips[0] = add(tips[0], tip)
+		}
+	}
+
+	return parents
+}
+
+// Octopus is a master branch where every so often a handful of short branches are merged back in at once
+func Octopus(r *rand.Rand, size int) [][]int {
+	parents := [][]int{nil}
+	master := 0
+	for len(parents) < size {
+		n := 3 + r.Intn(4)
+		if r.Intn(5) != 0 || size-len(parents) < 4*n+1 {
+			parents = append(parents, []int{master})
+			master = len(parents) - 1
+			continue
+		}
+
+		merge := []int{master}
+		for b := 0; b < n; b++ {
+			tip := master
+			for c := 0; c <= r.Intn(3); c++ {
+				parents = append(parents, []int{tip})
+				tip = len(parents) - 1
+			}
+			merge = append(merge, tip)
+		}
+		parents = append(parents, merge)
+		master = len(parents) - 1
+	}
+	return parents
+}
+
+// commitIDs makes up a random sha for every commit
+func commitIDs(r *rand.Rand, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%016x%016x%08x", r.Uint64(), r.Uint64(), r.Uint32())
+	}
+	return ids
+}
+
+// repo turns the parents into a Repo, newest commit first like git log
+func repo(name string, ids []string, parents [][]int) bisect.Repo {
+	entries := make([]bisect.DAGEntry, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		var ps []string
+		for _, p := range parents[i] {
+			ps = append(ps, ids[p])
+		}
+		entries = append(entries, bisect.NewDAGEntry(ids[i], ps...))
+	}
+
+	return bisect.Repo{
+		Name:          name,
+		InstanceCount: 1,
+		Dag:           entries,
+	}
+}
+
+// Repo generates a repo of the family with about size commits
+// The same seed always gives the same repo.
+func Repo(f Family, name string, size int, seed int64) bisect.Repo {
+	r := rand.New(rand.NewSource(seed))
+	parents := f(r, size)
+	return repo(name, commitIDs(r, len(parents)), parents)
+}
+
+// TestCase generates a repo of the family along with a problem on it
+// The bad commit is the newest, the good commit is in the oldest quarter of its first-parent chain (like a release
+// on master, rather than a commit off on a branch), and the bug is any commit between them: a descendant of the good
+// commit and an ancestor of the bad one.
+func TestCase(f Family, name string, size int, seed int64) *bisect.TestCase {
+	r := rand.New(rand.NewSource(seed))
+	parents := f(r, size)
+	ids := commitIDs(r, len(parents))
+	n := len(parents)
+
+	bad := n - 1
+	ancestors := ancestorsOf(parents, bad)
+
+	// The first-parent chain of the bad commit, oldest first
+	chain := []int{bad}
+	for c := bad; len(parents[c]) > 0; c = parents[c][0] {
+		chain = append(chain, parents[c][0])
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	good := chain[0]
+	if early := chain[:len(chain)-1]; len(early) > 0 {
+		early = early[:len(early)/4+1]
+		good = early[r.Intn(len(early))]
+	}
+
+	var candidates []int
+	afterGood := descendantsOf(parents, good)
+	for i := range parents {
+		if ancestors[i] && afterGood[i] && i != good {
+			candidates = append(candidates, i)
+		}
+	}
+	bug := candidates[r.Intn(len(candidates))]
+
+	var allBad []string
+	for i, buggy := range descendantsOf(parents, bug) {
+		if buggy {
+			allBad = append(allBad, ids[i])
+		}
+	}
+
+	return &bisect.TestCase{
+		Problem: bisect.ProblemInstance{
+			Repo: repo(name, ids, parents),
+			Instance: bisect.Instance{
+				Good: ids[good],
+				Bad:  ids[bad],
+			},
+		},
+		Bug:    ids[bug],
+		AllBad: allBad,
+	}
+}
+
+// ancestorsOf marks the commit and all of its ancestors
+func ancestorsOf(parents [][]int, commit int) []bool {
+	ancestors := make([]bool, len(parents))
+	ancestors[commit] = true
+	for i := commit; i >= 0; i-- {
+		if !ancestors[i] {
+			continue
+		}
+		for _, p := range parents[i] {
+			ancestors[p] = true
+		}
+	}
+	return ancestors
+}
+
+// descendantsOf marks the commit and everything descended from it
+// Parents always come first, so one pass finds them all.
+func descendantsOf(parents [][]int, commit int) []bool {
+	descendants := make([]bool, len(parents))
+	for i := commit; i < len(parents); i++ {
+		descendants[i] = i == commit
+		for _, p := range parents[i] {
+			descendants[i] = descendants[i] || descendants[p]
+		}
+	}
+	return descendants
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package gen
+
+import (
+	"fmt"
+	"testing"
+
+	bisect "github.com/jamesjarvis/git-bisect/pkg/bisect"
+)
+
+// Every family's problems can be solved: the bug is a descendant of the good commit, an ancestor of the bad one
+// (or the bad one itself) and bad, and the good commit is on the bad commit's first-parent chain
+func TestTestCase(t *testing.T) {
+	for _, family := range Names() {
+		for _, size := range []int{2, Sizes["tiny"], Sizes["small"]} {
+			for seed := int64(0); seed < 5; seed++ {
+				name := fmt.Sprintf("%v-%v-%v", family, size, seed)
+				c := TestCase(Families[family], name, size, seed)
+				good, bad, bug := c.Problem.Instance.Good, c.Problem.Instance.Bad, c.Bug
+
+				parents := make(map[string][]string)
+				for _, e := range c.Problem.Repo.Dag {
+					parents[e.Commit] = e.Parents
+				}
+				if good == bad {
+					t.Errorf("%v: the good commit is the bad one", name)
+				}
+				onChain := false
+				for v := bad; ; v = parents[v][0] {
+					onChain = onChain || v == good
+					if len(parents[v]) == 0 {
+						break
+					}
+				}
+				if !onChain {
+					t.Errorf("%v: the good commit %v isn't on the first-parent chain of %v", name, good, bad)
+				}
+
+				d := bisect.DAGMaker(&c.Problem.Repo)
+				ancestors := func(v string) map[string]bool {
+					found, err := d.GetOrderedAncestors(v)
+					if err != nil {
+						t.Fatal(err)
+					}
+					set := make(map[string]bool)
+					for _, a := range found {
+						set[a] = true
+					}
+					return set
+				}
+				if !ancestors(bug)[good] {
+					t.Errorf("%v: the bug %v isn't a descendant of the good commit %v", name, bug, good)
+				}
+				if bug != bad && !ancestors(bad)[bug] {
+					t.Errorf("%v: the bug %v isn't an ancestor of the bad commit %v", name, bug, bad)
+				}
+				listed := false
+				for _, v := range c.AllBad {
+					listed = listed || v == bug
+				}
+				if !listed {
+					t.Errorf("%v: the bug %v isn't in AllBad", name, bug)
+				}
+				if err := c.Validate(); err != nil {
+					t.Errorf("%v: %v", name, err)
+				}
+			}
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int{"tiny": 20, "large": 20000, "2": 2, "137": 137, "1": 0, "0": 0, "-5": 0, "huge": 0}
+	for s, want := range tests {
+		size, err := ParseSize(s)
+		if size != want || (err != nil) != (want == 0) {
+			t.Errorf("%v: got %v (%v), want %v", s, size, err, want)
+		}
+	}
+}
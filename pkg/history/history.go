@@ -0,0 +1,245 @@
+// Package history keeps every run's results in an append-only JSON lines file, so runs can be compared
+// after changing the heuristics.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+// Run is a single run of the solver
+type Run struct {
+	ID       string           `json:"id"`
+	Revision string           `json:"revision"`
+	Params   dag.ParamConfig  `json:"params"`
+	Start    time.Time        `json:"start"`
+	End      time.Time        `json:"end"`
+	Problems []report.Problem `json:"problems"`
+}
+
+// idFormat is the start time to the nanosecond, so runs started in the same second still get their own IDs
+const idFormat = "20060102-150405.000000000"
+
+// NewRun creates a run with an ID based on the start time, for the git revision of this project (see Revision)
+func NewRun(revision string, params dag.ParamConfig, problems []report.Problem, start, end time.Time) Run {
+	return Run{
+		ID:       start.Format(idFormat),
+		Revision: revision,
+		Params:   params,
+		Start:    start,
+		End:      end,
+		Problems: problems,
+	}
+}
+
+// Revision is the git revision of the directory, with "-dirty" if there are uncommitted changes,
+// or "unknown" if it isn't in a git repository
+func Revision(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	revision := strings.TrimSpace(string(out))
+
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--untracked-files=no").Output()
+	if err == nil && len(strings.TrimSpace(string(status))) > 0 {
+		revision += "-dirty"
+	}
+
+	return revision
+}
+
+// BuildRevision is the git revision the running binary was built from, when go build stamped it in,
+// or else the revision of the directory (see Revision), which has to be read before anything is written to it
+func BuildRevision(dir string) string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if revision, ok := buildRevision(info.Settings); ok {
+			return revision
+		}
+	}
+	return Revision(dir)
+}
+
+// buildRevision is the revision in the build settings, shortened like git rev-parse --short,
+// with "-dirty" if the checkout had uncommitted changes
+func buildRevision(settings []debug.BuildSetting) (string, bool) {
+	var revision string
+	modified := false
+	for _, s := range settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "", false
+	}
+
+	if len(revision) > 7 {
+		revision = revision[:7]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision, true
+}
+
+// Append adds the run to the end of the history file, creating it if needed
+func Append(path string, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// Load reads every run in the history file, oldest first
+func Load(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var run Run
+		err := json.Unmarshal(scanner.Bytes(), &run)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %v: %v", path, line, err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, scanner.Err()
+}
+
+// Find returns the run with the ID, where "latest" is the last run, "latest~1" the one before it and so on
+// As the IDs are long, any start of one that only one run has will do too, like the ID to the second.
+func Find(runs []Run, id string) (Run, error) {
+	if strings.HasPrefix(id, "latest") {
+		back := 0
+		if rest := strings.TrimPrefix(id, "latest"); rest != "" {
+			_, err := fmt.Sscanf(rest, "~%d", &back)
+			if err != nil {
+				return Run{}, fmt.Errorf("bad run '%s', expected latest~N", id)
+			}
+		}
+		if back < 0 || back >= len(runs) {
+			return Run{}, fmt.Errorf("there are only %v runs, so there is no '%s'", len(runs), id)
+		}
+		return runs[len(runs)-1-back], nil
+	}
+
+	// Older histories have IDs only to the second, so the last one wins
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].ID == id {
+			return runs[i], nil
+		}
+	}
+
+	var found []Run
+	for _, run := range runs {
+		if strings.HasPrefix(run.ID, id) {
+			found = append(found, run)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Run{}, fmt.Errorf("no run '%s'", id)
+	case 1:
+		return found[0], nil
+	default:
+		return Run{}, fmt.Errorf("%v runs start with '%s'", len(found), id)
+	}
+}
+
+// Change is how a single problem differs between two runs
+type Change struct {
+	Name   string          `json:"name"`
+	Before *report.Problem `json:"before,omitempty"`
+	After  *report.Problem `json:"after,omitempty"`
+	// Delta is the change in questions asked, when it was correct both times
+	Delta int `json:"delta"`
+	// Worse is set if it asked more questions, went from correct to anything else, or is missing from the new run
+	// (say it crashed or was stopped early)
+	Worse bool `json:"worse"`
+}
+
+// Compare matches up the problems in the two runs by name, returning those that changed with the worst first
+func Compare(before, after Run) []Change {
+	problems := make(map[string]*Change)
+	for i := range before.Problems {
+		p := &before.Problems[i]
+		problems[p.Name] = &Change{Name: p.Name, Before: p}
+	}
+	for i := range after.Problems {
+		p := &after.Problems[i]
+		if _, exists := problems[p.Name]; !exists {
+			problems[p.Name] = &Change{Name: p.Name}
+		}
+		problems[p.Name].After = p
+	}
+
+	var changes []Change
+	for _, c := range problems {
+		switch {
+		case c.Before == nil:
+		case c.After == nil:
+			c.Worse = true
+		case c.Before.Outcome == report.Correct && c.After.Outcome == report.Correct:
+			c.Delta = c.After.Questions - c.Before.Questions
+			c.Worse = c.Delta > 0
+			if c.Delta == 0 {
+				continue
+			}
+		case c.Before.Outcome == c.After.Outcome:
+			continue
+		default:
+			c.Worse = c.Before.Outcome == report.Correct
+		}
+		changes = append(changes, *c)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Worse != changes[j].Worse {
+			return changes[i].Worse
+		}
+		if changes[i].Delta != changes[j].Delta {
+			return changes[i].Delta > changes[j].Delta
+		}
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
@@ -0,0 +1,238 @@
+package history
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesjarvis/git-bisect/pkg/dag"
+	"github.com/jamesjarvis/git-bisect/pkg/report"
+)
+
+var start = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+func correct(name string, questions int) report.Problem {
+	return report.Problem{Name: name, Family: report.Family(name), Outcome: report.Correct, Questions: questions}
+}
+
+func failed(name, outcome string) report.Problem {
+	return report.Problem{Name: name, Family: report.Family(name), Outcome: outcome}
+}
+
+func TestRunIDs(t *testing.T) {
+	params := dag.ParamConfig{Limit: 10}
+	first := NewRun("abc", params, nil, start, start.Add(time.Second))
+	second := NewRun("abc", params, nil, start.Add(time.Millisecond), start.Add(time.Second))
+	if first.ID == second.ID {
+		t.Errorf("runs started in the same second both have the ID %v", first.ID)
+	}
+	if first.ID != "20210304-050607.000000000" {
+		t.Errorf("got ID %v", first.ID)
+	}
+	if first.Revision != "abc" || first.Params != params {
+		t.Errorf("got %+v", first)
+	}
+}
+
+func TestAppendLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	runs := []Run{
+		NewRun("abc", dag.ParamConfig{Limit: 10}, []report.Problem{correct("react0", 3)}, start, start.Add(time.Second)),
+		NewRun("def-dirty", dag.ParamConfig{Limit: 20}, []report.Problem{failed("react0", report.Wrong)}, start.Add(time.Minute), start.Add(2*time.Minute)),
+	}
+	for _, run := range runs {
+		if err := Append(path, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(runs) {
+		t.Fatalf("loaded %v runs, want %v", len(loaded), len(runs))
+	}
+	for i := range runs {
+		if loaded[i].ID != runs[i].ID || loaded[i].Revision != runs[i].Revision || !reflect.DeepEqual(loaded[i].Problems, runs[i].Problems) {
+			t.Errorf("run %v: loaded %+v, want %+v", i, loaded[i], runs[i])
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("{}\nnope\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want one for line 2", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	runs := []Run{
+		{ID: "20210304-050607"},
+		{ID: "20210304-050607.100000000", Revision: "second"},
+		{ID: "20210304-050607.200000000"},
+		{ID: "20210305-010203.000000000"},
+	}
+	// An older history's ID to the second that turns up twice means the last of them
+	runs = append(runs, Run{ID: "20210304-050607", Revision: "again"})
+
+	tests := []struct {
+		id   string
+		want string
+		err  string
+	}{
+		{id: "latest", want: "20210304-050607"},
+		{id: "latest~0", want: "20210304-050607"},
+		{id: "latest~1", want: "20210305-010203.000000000"},
+		{id: "latest~4", want: "20210304-050607"},
+		{id: "latest~5", err: "there are only 5 runs"},
+		{id: "latest~-1", err: "there are only 5 runs"},
+		{id: "latest1", err: "expected latest~N"},
+		{id: "20210304-050607.100000000", want: "20210304-050607.100000000"},
+		{id: "20210304-050607.1", want: "20210304-050607.100000000"},
+		{id: "20210305", want: "20210305-010203.000000000"},
+		{id: "20210304-050607.", err: "2 runs start with"},
+		{id: "2022", err: "no run '2022'"},
+	}
+	for _, tt := range tests {
+		run, err := Find(runs, tt.id)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: got error %v, want %q", tt.id, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tt.id, err)
+		} else if run.ID != tt.want {
+			t.Errorf("%v: found %v, want %v", tt.id, run.ID, tt.want)
+		}
+	}
+
+	if run, _ := Find(runs, "20210304-050607"); run.Revision != "again" {
+		t.Errorf("found the %v run, want the last one with the ID", run.Revision)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := Run{Problems: []report.Problem{
+		correct("same", 5),
+		correct("slower", 5),
+		correct("faster", 5),
+		correct("broke", 5),
+		failed("fixed", report.Wrong),
+		failed("still-wrong", report.Wrong),
+		failed("gave-up-now", report.Wrong),
+		correct("removed", 5),
+		failed("wrong-removed", report.Wrong),
+	}}
+	after := Run{Problems: []report.Problem{
+		correct("same", 5),
+		correct("slower", 7),
+		correct("faster", 2),
+		failed("broke", report.GaveUp),
+		correct("fixed", 9),
+		failed("still-wrong", report.Wrong),
+		failed("gave-up-now", report.GaveUp),
+		correct("added", 5),
+	}}
+
+	var got []string
+	for _, c := range Compare(before, after) {
+		got = append(got, c.Name)
+		switch c.Name {
+		case "slower":
+			if !c.Worse || c.Delta != 2 {
+				t.Errorf("slower: got %+v", c)
+			}
+		case "faster":
+			if c.Worse || c.Delta != -3 {
+				t.Errorf("faster: got %+v", c)
+			}
+		case "broke":
+			if !c.Worse {
+				t.Errorf("broke: got %+v", c)
+			}
+		case "removed", "wrong-removed":
+			if !c.Worse || c.Delta != 0 {
+				t.Errorf("%v: got %+v", c.Name, c)
+			}
+		case "fixed", "gave-up-now", "added":
+			if c.Worse || c.Delta != 0 {
+				t.Errorf("%v: got %+v", c.Name, c)
+			}
+		}
+	}
+
+	// The worse ones first, the most questions added first, then by name
+	want := []string{"slower", "broke", "removed", "wrong-removed", "added", "fixed", "gave-up-now", "faster"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v, want %v", got, want)
+	}
+}
+
+func TestRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	dir := t.TempDir()
+	if got := Revision(dir); got != "unknown" {
+		t.Errorf("outside a git repository got %v, want unknown", got)
+	}
+
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("init", "-q")
+	run("add", "a")
+	run("commit", "-q", "-m", "add a")
+
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha := strings.TrimSpace(string(out))
+	if got := Revision(dir); got != sha {
+		t.Errorf("got %v, want %v", got, sha)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Revision(dir); got != sha+"-dirty" {
+		t.Errorf("with a change got %v, want %v-dirty", got, sha)
+	}
+}
+
+func TestBuildRevision(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		settings []debug.BuildSetting
+		want     string
+		ok       bool
+	}{
+		{[]debug.BuildSetting{{Key: "vcs.revision", Value: sha}, {Key: "vcs.modified", Value: "false"}}, "0123456", true},
+		{[]debug.BuildSetting{{Key: "vcs.modified", Value: "true"}, {Key: "vcs.revision", Value: sha}}, "0123456-dirty", true},
+		{[]debug.BuildSetting{{Key: "vcs.revision", Value: "abc"}}, "abc", true},
+		{[]debug.BuildSetting{{Key: "vcs.modified", Value: "true"}}, "", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := buildRevision(tt.settings)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%v: got %v %v, want %v %v", tt.settings, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// emoji is how each outcome is shown in the text report, like the original results.txt
+var emoji = map[string]string{
+	Correct:   "✅",
+	Wrong:     "❌",
+	GaveUp:    "😤",
+	Submitted: "📨",
+}
+
+func (r *Report) writeText(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	for _, p := range r.Problems {
+		if p.Outcome == Correct && p.Bounded {
+			ew.printf("%v %v : %v (%v)\n", emoji[p.Outcome], p.Name, p.Questions, p.bound())
+		} else if p.Outcome == Correct || p.Outcome == Submitted {
+			ew.printf("%v %v : %v\n", emoji[p.Outcome], p.Name, p.Questions)
+		} else {
+			ew.printf("%v %v\n", emoji[p.Outcome], p.Name)
+		}
+	}
+
+	s := r.Summary
+	ew.printf("Total problems: %v\n", s.Problems)
+	ew.printf("Correct solutions: %v, Incorrect solutions: %v, GaveUp: %v\n", s.Correct, s.Wrong, s.GaveUp)
+	if s.Submitted > 0 {
+		ew.printf("Submitted but not scored (stopped early): %v\n", s.Submitted)
+	}
+	ew.printf("Total questions asked: %v\n", s.Questions)
+	if s.Correct > 0 {
+		ew.printf("Average questions per correct problem: %.2f\n", s.Mean)
+		ew.printf("Questions per correct problem p50: %v, p90: %v, p99: %v, max: %v\n", s.P50, s.P90, s.P99, s.Max)
+	}
+	if s.Bounded > 0 {
+		ew.printf("Questions over optimal: %v in total, %.2f on average (over %v problems)\n", s.OverOptimal, s.MeanOverOptimal, s.Bounded)
+	}
+
+	if len(r.Families) > 1 {
+		ew.printf("Families:\n")
+		for _, f := range r.Families {
+			ew.printf("  %v: %v/%v correct, average %.2f, p90 %v, max %v, average over optimal %.2f\n", f.Name, f.Correct, f.Problems, f.Mean, f.P90, f.Max, f.MeanOverOptimal)
+		}
+	}
+
+	ew.printf("Started at: %v\n", r.Start.String())
+	ew.printf("Completed at: %v\n", r.End.String())
+	ew.printf("Time taken: %v\n", r.End.Sub(r.Start).String())
+
+	return ew.err
+}
+
+func (r *Report) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"name", "family", "outcome", "questions", "optimal", "over_optimal"})
+	for _, p := range r.Problems {
+		optimal, over := "", ""
+		if p.Bounded {
+			optimal, over = strconv.Itoa(p.Optimal), strconv.Itoa(p.OverOptimal())
+		}
+		cw.Write([]string{p.Name, p.Family, p.Outcome, strconv.Itoa(p.Questions), optimal, over})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Report) writeMarkdown(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	ew.printf("# Results\n\n")
+	ew.printf("Started at %v, took %v.\n\n", r.Start.Format("2006-01-02 15:04:05"), r.End.Sub(r.Start))
+
+	ew.printf("| Family | Problems | Correct | Wrong | GaveUp | Submitted | Questions | Mean | p50 | p90 | p99 | Max | Mean over optimal |\n")
+	ew.printf("|---|---:|---:|---:|---:|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	// A copy, as appending straight to r.Families could write the total into its spare capacity
+	rows := make([]Summary, 0, len(r.Families)+1)
+	rows = append(append(rows, r.Families...), r.Summary)
+	for i, s := range rows {
+		name := s.Name
+		if i == len(rows)-1 {
+			name = "**" + name + "**"
+		}
+		ew.printf("| %v | %v | %v | %v | %v | %v | %v | %.2f | %v | %v | %v | %v | %.2f |\n",
+			name, s.Problems, s.Correct, s.Wrong, s.GaveUp, s.Submitted, s.Questions, s.Mean, s.P50, s.P90, s.P99, s.Max, s.MeanOverOptimal)
+	}
+
+	ew.printf("\n| Problem | Outcome | Questions | Optimal |\n")
+	ew.printf("|---|---|---:|---:|\n")
+	for _, p := range r.Problems {
+		optimal := ""
+		if p.Bounded {
+			optimal = strconv.Itoa(p.Optimal)
+		}
+		ew.printf("| %v | %v %v | %v | %v |\n", p.Name, emoji[p.Outcome], p.Outcome, p.Questions, optimal)
+	}
+
+	return ew.err
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes a test suite per family, where wrong answers and giving up are failures
+func (r *Report) writeJUnit(w io.Writer) error {
+	suites := junitSuites{
+		Time: r.End.Sub(r.Start).Seconds(),
+	}
+
+	index := make(map[string]int)
+	for _, f := range r.Families {
+		index[f.Name] = len(suites.Suites)
+		suites.Suites = append(suites.Suites, junitSuite{Name: f.Name})
+	}
+
+	for _, p := range r.Problems {
+		suite := &suites.Suites[index[p.Family]]
+		c := junitCase{
+			Name:      p.Name,
+			ClassName: p.Family,
+		}
+
+		switch p.Outcome {
+		case Correct:
+			c.SystemOut = fmt.Sprintf("%v questions", p.Questions)
+			if p.Bounded {
+				c.SystemOut += fmt.Sprintf(", %v over optimal (%v)", p.OverOptimal(), p.bound())
+			}
+		case Submitted:
+			c.Skipped = &junitMessage{fmt.Sprintf("submitted after %v questions, but never scored", p.Questions)}
+			suite.Skipped++
+		default:
+			c.Failure = &junitMessage{p.Outcome}
+			suite.Failures++
+		}
+
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	for _, s := range suites.Suites {
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+		suites.Skipped += s.Skipped
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(suites)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// errWriter remembers the first error, so the writers don't have to check every line
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, a ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, a...)
+}
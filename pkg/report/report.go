@@ -0,0 +1,242 @@
+// Package report turns the server's Score map into results reports, in a few different formats.
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The outcomes a problem can have
+const (
+	Correct = "Correct"
+	Wrong   = "Wrong"
+	GaveUp  = "GaveUp"
+	// Submitted means we were stopped before the server could tell us how we did
+	Submitted = "Submitted"
+)
+
+// The report formats
+const (
+	Text     = "text"
+	JSON     = "json"
+	CSV      = "csv"
+	Markdown = "markdown"
+	JUnit    = "junit"
+)
+
+// Formats lists every format Write understands
+var Formats = []string{Text, JSON, CSV, Markdown, JUnit}
+
+// Problem is how a single problem went
+type Problem struct {
+	Name      string `json:"name"`
+	Family    string `json:"family"`
+	Outcome   string `json:"outcome"`
+	Questions int    `json:"questions"`
+	// Optimal is the fewest questions that could have been needed in the worst case, if Bounded
+	// It is the true optimum for small problems (Exact) and the information-theoretic bound otherwise,
+	// so a lucky run can come in under it.
+	Optimal int  `json:"optimal"`
+	Bounded bool `json:"bounded"`
+	Exact   bool `json:"exact"`
+}
+
+// bound describes the optimal number of questions, and whether it is exact
+func (p Problem) bound() string {
+	if p.Exact {
+		return fmt.Sprintf("optimal %v", p.Optimal)
+	}
+	return fmt.Sprintf("lower bound %v", p.Optimal)
+}
+
+// OverOptimal is how many more questions were asked than the optimal worst case
+func (p Problem) OverOptimal() int {
+	return p.Questions - p.Optimal
+}
+
+// Summary is the totals and question percentiles for a set of problems
+// The question statistics only count the correct problems.
+type Summary struct {
+	Name      string  `json:"name"`
+	Problems  int     `json:"problems"`
+	Correct   int     `json:"correct"`
+	Wrong     int     `json:"wrong"`
+	GaveUp    int     `json:"gave_up"`
+	Submitted int     `json:"submitted"`
+	Questions int     `json:"questions"`
+	Mean      float64 `json:"mean"`
+	P50       int     `json:"p50"`
+	P90       int     `json:"p90"`
+	P99       int     `json:"p99"`
+	Max       int     `json:"max"`
+	// OverOptimal is the total questions over optimal, for the correct problems with a bound
+	OverOptimal     int     `json:"over_optimal"`
+	MeanOverOptimal float64 `json:"mean_over_optimal"`
+	Bounded         int     `json:"bounded"`
+}
+
+// Report is everything we know about a run
+type Report struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Summary  Summary   `json:"summary"`
+	Families []Summary `json:"families"`
+	Problems []Problem `json:"problems"`
+}
+
+// New builds the report from how each problem went
+func New(problems []Problem, start, end time.Time) *Report {
+	r := &Report{
+		Start:    start,
+		End:      end,
+		Problems: append([]Problem(nil), problems...),
+	}
+
+	sort.Slice(r.Problems, func(i, j int) bool {
+		return r.Problems[i].Name < r.Problems[j].Name
+	})
+
+	r.Summary = summarise("total", r.Problems)
+
+	families := make(map[string][]Problem)
+	for _, p := range r.Problems {
+		families[p.Family] = append(families[p.Family], p)
+	}
+	for family, problems := range families {
+		r.Families = append(r.Families, summarise(family, problems))
+	}
+	sort.Slice(r.Families, func(i, j int) bool {
+		return r.Families[i].Name < r.Families[j].Name
+	})
+
+	return r
+}
+
+var familyNumber = regexp.MustCompile(`-?[0-9]+$`)
+
+// Family is the problem name without its number, so "small-latex-13" is in "small-latex"
+func Family(name string) string {
+	family := familyNumber.ReplaceAllString(name, "")
+	if family == "" {
+		return name
+	}
+	return family
+}
+
+func summarise(name string, problems []Problem) Summary {
+	s := Summary{
+		Name:     name,
+		Problems: len(problems),
+	}
+
+	var questions []int
+	for _, p := range problems {
+		switch p.Outcome {
+		case Correct:
+			s.Correct++
+			questions = append(questions, p.Questions)
+			if p.Bounded {
+				s.Bounded++
+				s.OverOptimal += p.OverOptimal()
+			}
+		case Wrong:
+			s.Wrong++
+		case GaveUp:
+			s.GaveUp++
+		case Submitted:
+			s.Submitted++
+		}
+		s.Questions += p.Questions
+	}
+
+	if s.Bounded > 0 {
+		s.MeanOverOptimal = float64(s.OverOptimal) / float64(s.Bounded)
+	}
+
+	if len(questions) == 0 {
+		return s
+	}
+
+	sort.Ints(questions)
+	total := 0
+	for _, q := range questions {
+		total += q
+	}
+	s.Mean = float64(total) / float64(len(questions))
+	s.P50 = percentile(questions, 50)
+	s.P90 = percentile(questions, 90)
+	s.P99 = percentile(questions, 99)
+	s.Max = questions[len(questions)-1]
+
+	return s
+}
+
+// percentile uses the nearest rank method on the sorted values
+func percentile(sorted []int, p float64) int {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// FormatFromPath guesses the format from the file extension, defaulting to Text
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON
+	case ".csv":
+		return CSV
+	case ".md", ".markdown":
+		return Markdown
+	case ".xml":
+		return JUnit
+	default:
+		return Text
+	}
+}
+
+// Write writes the report in the given format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case Text, "":
+		return r.writeText(w)
+	case JSON:
+		return r.writeJSON(w)
+	case CSV:
+		return r.writeCSV(w)
+	case Markdown:
+		return r.writeMarkdown(w)
+	case JUnit:
+		return r.writeJUnit(w)
+	default:
+		return fmt.Errorf("unknown report format '%s', use one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Save writes the report to a file, guessing the format from the extension if it is empty
+func (r *Report) Save(path, format string) error {
+	if format == "" {
+		format = FormatFromPath(path)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = r.Write(f, format)
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
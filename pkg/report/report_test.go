@@ -0,0 +1,257 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		sorted []int
+		p      float64
+		want   int
+	}{
+		{[]int{7}, 50, 7},
+		{[]int{7}, 99, 7},
+		{[]int{1, 2}, 50, 1},
+		{[]int{1, 2}, 90, 2},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 50, 5},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 90, 9},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 99, 10},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("p%v of %v: got %v, want %v", tt.p, tt.sorted, got, tt.want)
+		}
+	}
+}
+
+func TestFamily(t *testing.T) {
+	tests := map[string]string{
+		"small-latex-13": "small-latex",
+		"react0":         "react",
+		"tiny-chain":     "tiny-chain",
+		"123":            "123",
+	}
+	for name, want := range tests {
+		if got := Family(name); got != want {
+			t.Errorf("%v: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+var start = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+// example is a run over two families, with every kind of outcome
+func example() *Report {
+	return New([]Problem{
+		{Name: "tiny-chain-2", Family: "tiny-chain", Outcome: Correct, Questions: 4, Optimal: 3, Bounded: true, Exact: true},
+		{Name: "tiny-chain-1", Family: "tiny-chain", Outcome: Correct, Questions: 2, Optimal: 2, Bounded: true, Exact: true},
+		{Name: "tiny-chain-3", Family: "tiny-chain", Outcome: Wrong, Questions: 5},
+		{Name: "react0", Family: "react", Outcome: Correct, Questions: 12, Optimal: 10, Bounded: true},
+		{Name: "react1", Family: "react", Outcome: GaveUp},
+		{Name: "react2", Family: "react", Outcome: Submitted, Questions: 6},
+	}, start, start.Add(90*time.Second))
+}
+
+func TestNew(t *testing.T) {
+	r := example()
+
+	var names []string
+	for _, p := range r.Problems {
+		names = append(names, p.Name)
+	}
+	if want := []string{"react0", "react1", "react2", "tiny-chain-1", "tiny-chain-2", "tiny-chain-3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("problems are in the order %v, want %v", names, want)
+	}
+
+	want := Summary{
+		Name: "total", Problems: 6, Correct: 3, Wrong: 1, GaveUp: 1, Submitted: 1, Questions: 29,
+		Mean: 6, P50: 4, P90: 12, P99: 12, Max: 12,
+		OverOptimal: 3, MeanOverOptimal: 1, Bounded: 3,
+	}
+	if r.Summary != want {
+		t.Errorf("got summary %+v, want %+v", r.Summary, want)
+	}
+
+	if len(r.Families) != 2 || r.Families[0].Name != "react" || r.Families[1].Name != "tiny-chain" {
+		t.Fatalf("got families %+v", r.Families)
+	}
+	if f := r.Families[1]; f.Correct != 2 || f.Wrong != 1 || f.Mean != 3 || f.Max != 4 || f.MeanOverOptimal != 0.5 {
+		t.Errorf("got tiny-chain %+v", f)
+	}
+}
+
+// With nothing correct there are no question statistics, rather than a division by zero
+func TestNoneCorrect(t *testing.T) {
+	r := New([]Problem{
+		{Name: "react0", Family: "react", Outcome: Wrong, Questions: 3},
+		{Name: "react1", Family: "react", Outcome: GaveUp},
+	}, start, start)
+
+	want := Summary{Name: "total", Problems: 2, Wrong: 1, GaveUp: 1, Questions: 3}
+	if r.Summary != want {
+		t.Errorf("got summary %+v, want %+v", r.Summary, want)
+	}
+
+	var b bytes.Buffer
+	if err := r.Write(&b, Text); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "Average") || strings.Contains(b.String(), "NaN") {
+		t.Errorf("question statistics without a correct problem:\n%v", b.String())
+	}
+	for _, format := range Formats {
+		if err := r.Write(&bytes.Buffer{}, format); err != nil {
+			t.Errorf("%v: %v", format, err)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var b bytes.Buffer
+	if err := example().Write(&b, Text); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"✅ react0 : 12 (lower bound 10)\n",
+		"😤 react1\n",
+		"📨 react2 : 6\n",
+		"✅ tiny-chain-2 : 4 (optimal 3)\n",
+		"❌ tiny-chain-3\n",
+		"Total problems: 6\n",
+		"Correct solutions: 3, Incorrect solutions: 1, GaveUp: 1\n",
+		"Submitted but not scored (stopped early): 1\n",
+		"Average questions per correct problem: 6.00\n",
+		"Questions per correct problem p50: 4, p90: 12, p99: 12, max: 12\n",
+		"Questions over optimal: 3 in total, 1.00 on average (over 3 problems)\n",
+		"  tiny-chain: 2/3 correct, average 3.00, p90 4, max 4, average over optimal 0.50\n",
+		"Time taken: 1m30s\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("no %q in\n%v", want, b.String())
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	r := example()
+	// Spare capacity after the families, which the total row must not be written into
+	r.Families = append(make([]Summary, 0, len(r.Families)+1), r.Families...)
+	families := append([]Summary(nil), r.Families...)
+
+	var b bytes.Buffer
+	if err := r.Write(&b, Markdown); err != nil {
+		t.Fatal(err)
+	}
+	if spare := r.Families[:len(r.Families)+1][len(r.Families)]; spare != (Summary{}) {
+		t.Errorf("the total was written after the families: %+v", spare)
+	}
+	if !reflect.DeepEqual(r.Families, families) {
+		t.Errorf("the families changed to %+v", r.Families)
+	}
+
+	for _, want := range []string{
+		"Started at 2021-03-04 05:06:07, took 1m30s.\n",
+		"| react | 3 | 1 | 0 | 1 | 1 | 18 | 12.00 | 12 | 12 | 12 | 12 | 2.00 |\n",
+		"| **total** | 6 | 3 | 1 | 1 | 1 | 29 | 6.00 | 4 | 12 | 12 | 12 | 1.00 |\n",
+		"| tiny-chain-3 | ❌ Wrong | 5 |  |\n",
+		"| tiny-chain-2 | ✅ Correct | 4 | 3 |\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("no %q in\n%v", want, b.String())
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := example()
+	var b bytes.Buffer
+	if err := r.Write(&b, JSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var read Report
+	if err := json.Unmarshal(b.Bytes(), &read); err != nil {
+		t.Fatal(err)
+	}
+	if !read.Start.Equal(r.Start) || !read.End.Equal(r.End) {
+		t.Errorf("read back times %v to %v, want %v to %v", read.Start, read.End, r.Start, r.End)
+	}
+	read.Start, read.End = r.Start, r.End
+	if !reflect.DeepEqual(&read, r) {
+		t.Errorf("read back\n%+v\nwant\n%+v", read, *r)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := example().Write(&b, CSV); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `name,family,outcome,questions,optimal,over_optimal
+react0,react,Correct,12,10,2
+react1,react,GaveUp,0,,
+react2,react,Submitted,6,,
+tiny-chain-1,tiny-chain,Correct,2,2,0
+tiny-chain-2,tiny-chain,Correct,4,3,1
+tiny-chain-3,tiny-chain,Wrong,5,,
+`
+	if b.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", b.String(), want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var b bytes.Buffer
+	if err := example().Write(&b, JUnit); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="6" failures="2" skipped="1" time="90">
+  <testsuite name="react" tests="3" failures="1" skipped="1">
+    <testcase name="react0" classname="react">
+      <system-out>12 questions, 2 over optimal (lower bound 10)</system-out>
+    </testcase>
+    <testcase name="react1" classname="react">
+      <failure message="GaveUp"></failure>
+    </testcase>
+    <testcase name="react2" classname="react">
+      <skipped message="submitted after 6 questions, but never scored"></skipped>
+    </testcase>
+  </testsuite>
+  <testsuite name="tiny-chain" tests="3" failures="1" skipped="0">
+    <testcase name="tiny-chain-1" classname="tiny-chain">
+      <system-out>2 questions, 0 over optimal (optimal 2)</system-out>
+    </testcase>
+    <testcase name="tiny-chain-2" classname="tiny-chain">
+      <system-out>4 questions, 1 over optimal (optimal 3)</system-out>
+    </testcase>
+    <testcase name="tiny-chain-3" classname="tiny-chain">
+      <failure message="Wrong"></failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if b.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", b.String(), want)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	err := example().Write(&bytes.Buffer{}, "yaml")
+	if err == nil || !strings.Contains(err.Error(), "unknown report format 'yaml'") {
+		t.Errorf("got error %v", err)
+	}
+	if got := FormatFromPath("results.MD"); got != Markdown {
+		t.Errorf("results.MD is %v, want markdown", got)
+	}
+}